@@ -5,19 +5,45 @@ import (
 	"errors"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/dghubble/oauth1"
 )
 
+// DefaultTwitterURL blablabla
+const DefaultTwitterURL = "https://api.twitter.com"
+
+// Endpoint blablabla
+type Endpoint struct {
+	RequestTokenURL string
+	AuthorizeURL    string
+	AccessTokenURL  string
+}
+
+// TwitterEndpoint returns Twitter's OAuth1 endpoint relative to baseURL (e.g.,
+// DefaultTwitterURL or a fake server's URL)
+func TwitterEndpoint(baseURL string) Endpoint {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	return Endpoint{
+		RequestTokenURL: baseURL + "/oauth/request_token",
+		AuthorizeURL:    baseURL + "/oauth/authorize",
+		AccessTokenURL:  baseURL + "/oauth/access_token",
+	}
+}
+
 // Oauth1Client blablabla
 type Oauth1Client interface {
-	AccessToken(requestToken, requestSecret, verifier string) (
+	AccessToken(ctx context.Context, requestToken, requestSecret, verifier string) (
 		accessToken, accessSecret string, err error,
 	)
 
 	AuthorizationURL(requestToken string) (*url.URL, error)
-	HTTPClient(accessToken, accessSecret string) (*http.Client, error)
-	RequestToken() (requestToken, requestSecret string, err error)
+	HTTPClient(ctx context.Context, accessToken, accessSecret string) (
+		*http.Client, error,
+	)
+
+	RequestToken(ctx context.Context) (requestToken, requestSecret string, err error)
 
 	ParseAuthorizationCallback(r *http.Request) (
 		requestToken, verifier string, err error,
@@ -46,11 +72,21 @@ type oauth1Client struct {
 }
 
 // NewOauth1Client blabla
-func NewOauth1Client(consumerKey, consumerSecret, callbackURL string) (
+func NewOauth1Client(
+	consumerKey,
+	consumerSecret,
+	callbackURL string,
+	endpoint Endpoint,
+) (
 	Oauth1Client, error,
 ) {
 
-	if consumerKey == "" || consumerSecret == "" || callbackURL == "" {
+	if consumerKey == "" ||
+		consumerSecret == "" ||
+		callbackURL == "" ||
+		endpoint.RequestTokenURL == "" ||
+		endpoint.AuthorizeURL == "" ||
+		endpoint.AccessTokenURL == "" {
 		return nil, errors.New("auth: a required parameter is missing -_-")
 	}
 
@@ -59,9 +95,9 @@ func NewOauth1Client(consumerKey, consumerSecret, callbackURL string) (
 		ConsumerSecret: consumerSecret,
 		CallbackURL:    callbackURL,
 		Endpoint: oauth1.Endpoint{
-			RequestTokenURL: "https://api.twitter.com/oauth/request_token",
-			AuthorizeURL:    "https://api.twitter.com/oauth/authorize",
-			AccessTokenURL:  "https://api.twitter.com/oauth/access_token",
+			RequestTokenURL: endpoint.RequestTokenURL,
+			AuthorizeURL:    endpoint.AuthorizeURL,
+			AccessTokenURL:  endpoint.AccessTokenURL,
 		},
 	}
 
@@ -75,8 +111,9 @@ func NewOauth1Client(consumerKey, consumerSecret, callbackURL string) (
 	}, nil
 }
 
-// AccessToken blabla
+// AccessToken gives up once ctx is done
 func (client *oauth1Client) AccessToken(
+	ctx context.Context,
 	requestToken,
 	requestSecret,
 	verifier string) (
@@ -87,7 +124,9 @@ func (client *oauth1Client) AccessToken(
 		return "", "", errors.New("auth: a required parameter is missing -_-")
 	}
 
-	return client.accessTokenImpl(requestToken, requestSecret, verifier)
+	return withContext(ctx, func() (string, string, error) {
+		return client.accessTokenImpl(requestToken, requestSecret, verifier)
+	})
 }
 
 // AuthorizationURL blabla
@@ -102,8 +141,12 @@ func (client *oauth1Client) AuthorizationURL(requestToken string) (
 	return client.authorizationURLImpl(requestToken)
 }
 
-// HTTPClient blabla
-func (client *oauth1Client) HTTPClient(accessToken, accessSecret string) (
+// HTTPClient returns a client whose requests are cancelled along with ctx
+func (client *oauth1Client) HTTPClient(
+	ctx context.Context,
+	accessToken,
+	accessSecret string,
+) (
 	*http.Client, error,
 ) {
 
@@ -112,15 +155,72 @@ func (client *oauth1Client) HTTPClient(accessToken, accessSecret string) (
 	}
 
 	token := client.newTokenImpl(accessToken, accessSecret)
-	return client.clientImpl(oauth1.NoContext, token), nil
+	httpClient := *client.clientImpl(ctx, token)
+
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	httpClient.Transport = &contextTransport{ctx, next}
+
+	return &httpClient, nil
+}
+
+// contextTransport sends requests with its ctx, since go-twitter doesn't take
+// one (and oauth1 only uses it to pick a base transport)
+type contextTransport struct {
+	ctx  context.Context
+	next http.RoundTripper
 }
 
-// RequestToken blabla
-func (client *oauth1Client) RequestToken() (
+func (transport *contextTransport) RoundTrip(req *http.Request) (
+	*http.Response, error,
+) {
+
+	return transport.next.RoundTrip(req.WithContext(transport.ctx))
+}
+
+// RequestToken gives up once ctx is done
+func (client *oauth1Client) RequestToken(ctx context.Context) (
 	requestToken, requestSecret string, err error,
 ) {
 
-	return client.requestTokenImpl()
+	return withContext(ctx, client.requestTokenImpl)
+}
+
+// withContext returns ctx's error as soon as ctx is done, oauth1 doesn't take a
+// context for request and access tokens so the call itself is left to finish in
+// the background and its result is dropped
+func withContext(
+	ctx context.Context,
+	call func() (token, secret string, err error),
+) (
+	token, secret string, err error,
+) {
+
+	if err := ctx.Err(); err != nil {
+		return "", "", err
+	}
+
+	type result struct {
+		token, secret string
+		err           error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		token, secret, err := call()
+		done <- result{token, secret, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.token, r.secret, r.err
+	case <-ctx.Done():
+		return "", "", ctx.Err()
+	}
 }
 
 // ParseAuthorizationCallback blabla
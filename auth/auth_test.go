@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/dghubble/oauth1"
 )
@@ -15,26 +17,60 @@ var validClient, _ = NewOauth1Client(
 	"consumerKey",
 	"consumerSecret",
 	"callbackURL",
+	TwitterEndpoint(DefaultTwitterURL),
 )
 
+func TestTwitterEndpoint(t *testing.T) {
+	want := Endpoint{
+		RequestTokenURL: "http://127.0.0.1:8080/oauth/request_token",
+		AuthorizeURL:    "http://127.0.0.1:8080/oauth/authorize",
+		AccessTokenURL:  "http://127.0.0.1:8080/oauth/access_token",
+	}
+
+	if got := TwitterEndpoint("http://127.0.0.1:8080/"); got != want {
+		t.Errorf("TwitterEndpoint() = %v, want %v", got, want)
+	}
+}
+
 func TestNewOauth1Client(t *testing.T) {
 	//
-	client, err := NewOauth1Client("blablabla", "blablabla", "blablabla")
+	client, err := NewOauth1Client(
+		"blablabla",
+		"blablabla",
+		"blablabla",
+		TwitterEndpoint(DefaultTwitterURL),
+	)
 
 	if err != nil {
-		t.Errorf(err.Error())
+		t.Error(err)
 	}
 
 	//
-	client, err = NewOauth1Client("blablabla", "", "blablabla")
+	client, err = NewOauth1Client(
+		"blablabla",
+		"",
+		"blablabla",
+		TwitterEndpoint(DefaultTwitterURL),
+	)
 
 	if err == nil || client != nil {
 		t.Errorf("should return an error when a required config value is missing!")
 	}
+
+	//
+	client, err = NewOauth1Client("blablabla", "blablabla", "blablabla", Endpoint{})
+
+	if err == nil || client != nil {
+		t.Errorf("should return an error when an endpoint URL is missing!")
+	}
 }
 
 func Test_oauth1Client_AccessToken(t *testing.T) {
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
 	type args struct {
+		ctx           context.Context
 		requestToken  string
 		requestSecret string
 		verifier      string
@@ -50,7 +86,7 @@ func Test_oauth1Client_AccessToken(t *testing.T) {
 		{
 			name:    "should return an error when a parameter is missing",
 			client:  &oauth1Client{},
-			args:    args{"blablabla", "", "blablabla"},
+			args:    args{context.Background(), "blablabla", "", "blablabla"},
 			wantErr: true,
 		},
 		{
@@ -69,7 +105,7 @@ func Test_oauth1Client_AccessToken(t *testing.T) {
 					return "accessTokenResult", "accessSecretResult", nil
 				},
 			},
-			args: args{"requestToken", "requestSecret", "verifier"},
+			args: args{context.Background(), "requestToken", "requestSecret", "verifier"},
 
 			wantAccessToken:  "accessTokenResult",
 			wantAccessSecret: "accessSecretResult",
@@ -91,13 +127,28 @@ func Test_oauth1Client_AccessToken(t *testing.T) {
 				},
 			},
 
-			args:    args{"requestToken", "requestSecret", "verifier"},
+			args:    args{context.Background(), "requestToken", "requestSecret", "verifier"},
+			wantErr: true,
+		},
+		{
+			name: "should give up once the context is done",
+			client: &oauth1Client{
+				accessTokenImpl: func(requestToken, requestSecret, verifier string) (
+					accessToken, accessSecret string, err error,
+				) {
+
+					return "accessTokenResult", "accessSecretResult", nil
+				},
+			},
+
+			args:    args{canceledCtx, "requestToken", "requestSecret", "verifier"},
 			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			gotAccessToken, gotAccessSecret, err := tt.client.AccessToken(
+				tt.args.ctx,
 				tt.args.requestToken,
 				tt.args.requestSecret,
 				tt.args.verifier,
@@ -188,7 +239,8 @@ func Test_oauth1Client_AuthorizationURL(t *testing.T) {
 }
 
 func Test_oauth1Client_HTTPClient(t *testing.T) {
-	client := &http.Client{}
+	ctx := context.WithValue(context.Background(), oauth1.HTTPClient, nil)
+	client := &http.Client{Timeout: time.Minute}
 	token := &oauth1.Token{}
 
 	type args struct {
@@ -212,8 +264,8 @@ func Test_oauth1Client_HTTPClient(t *testing.T) {
 
 					return token
 				},
-				clientImpl: func(ctx context.Context, t0 *oauth1.Token) *http.Client {
-					if ctx != oauth1.NoContext || t0 != token {
+				clientImpl: func(ctx0 context.Context, t0 *oauth1.Token) *http.Client {
+					if ctx0 != ctx || t0 != token {
 						t.Errorf("Whaaat!")
 					}
 
@@ -221,7 +273,10 @@ func Test_oauth1Client_HTTPClient(t *testing.T) {
 				},
 			},
 			args: args{"accessToken", "accessSecret"},
-			want: client,
+			want: &http.Client{
+				Transport: &contextTransport{ctx, http.DefaultTransport},
+				Timeout:   time.Minute,
+			},
 		},
 		{
 			name:    "should return an error when a parameter is missing",
@@ -232,6 +287,7 @@ func Test_oauth1Client_HTTPClient(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got, err := tt.client.HTTPClient(
+				ctx,
 				tt.args.accessToken,
 				tt.args.accessSecret,
 			)
@@ -250,9 +306,38 @@ func Test_oauth1Client_HTTPClient(t *testing.T) {
 	}
 }
 
+func Test_contextTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {},
+	))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client, err := validClient.HTTPClient(ctx, "accessToken", "accessSecret")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Get(server.URL); err != nil {
+		t.Errorf("Whaaat! %v", err)
+	}
+
+	//
+	cancel()
+
+	if _, err := client.Get(server.URL); err == nil {
+		t.Errorf("Should cancel requests along with the context")
+	}
+}
+
 func Test_oauth1Client_RequestToken(t *testing.T) {
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
 	tests := []struct {
 		name              string
+		ctx               context.Context
 		client            *oauth1Client
 		wantRequestToken  string
 		wantRequestSecret string
@@ -260,6 +345,7 @@ func Test_oauth1Client_RequestToken(t *testing.T) {
 	}{
 		{
 			name: "should pass and return values using actual implementation",
+			ctx:  context.Background(),
 			client: &oauth1Client{
 				requestTokenImpl: func() (
 					requestToken,
@@ -276,6 +362,7 @@ func Test_oauth1Client_RequestToken(t *testing.T) {
 		},
 		{
 			name: "should return actual implementation error",
+			ctx:  context.Background(),
 			client: &oauth1Client{
 				requestTokenImpl: func() (
 					requestToken, requestSecret string, err error,
@@ -285,12 +372,26 @@ func Test_oauth1Client_RequestToken(t *testing.T) {
 				},
 			},
 
+			wantErr: true,
+		},
+		{
+			name: "should give up once the context is done",
+			ctx:  canceledCtx,
+			client: &oauth1Client{
+				requestTokenImpl: func() (
+					requestToken, requestSecret string, err error,
+				) {
+
+					return "requestToken", "requestSecret", nil
+				},
+			},
+
 			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotRequestToken, gotRequestSecret, err := tt.client.RequestToken()
+			gotRequestToken, gotRequestSecret, err := tt.client.RequestToken(tt.ctx)
 			if (err != nil) != tt.wantErr {
 				t.Errorf(
 					"oauth1Client.RequestToken() error = %v, wantErr %v",
@@ -0,0 +1,371 @@
+// Package collector fetches the timelines of opted-in accounts in the
+// background, so their stored history has no gaps between visits
+package collector
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/Ahimta/tweeters-stats-golang/services"
+	"github.com/Ahimta/tweeters-stats-golang/storage"
+)
+
+// failing accounts wait interval, then twice that, and so on up to this
+const maxBackoff = 24 * time.Hour
+
+// ErrNotOptedIn is returned for accounts that aren't collected
+var ErrNotOptedIn = errors.New("collector: account isn't opted in")
+
+// Status blablabla
+type Status struct {
+	Running  bool            `json:"running"`
+	Interval string          `json:"interval"`
+	Accounts []AccountStatus `json:"accounts"`
+}
+
+// AccountStatus is the outcome of the last collection of an account
+type AccountStatus struct {
+	Account string `json:"account"`
+
+	LastRunAt *time.Time `json:"lastRunAt,omitempty"`
+	LastError string     `json:"lastError,omitempty"`
+	LastAdded int        `json:"lastAdded"`
+	Failures  int        `json:"failures"`
+	NextRunAt time.Time  `json:"nextRunAt"`
+}
+
+type account struct {
+	status     AccountStatus
+	collection storage.Collection
+}
+
+// Collector periodically fetches the tweets each opted-in account got since
+// its newest stored tweet, it skips accounts until their rate limit resets and
+// backs off exponentially from failing ones
+type Collector struct {
+	service     services.IncrementalTweetsService
+	tweets      storage.Store
+	collections storage.CollectionStore
+	interval    time.Duration
+	nowImpl     func() time.Time
+
+	mutex    sync.Mutex
+	accounts map[string]*account
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// New returns a stopped Collector that fetches every account once per
+// interval, opted-in accounts are loaded from collections
+func New(
+	service services.IncrementalTweetsService,
+	tweets storage.Store,
+	collections storage.CollectionStore,
+	interval time.Duration,
+) (*Collector, error) {
+
+	if interval <= 0 {
+		return nil, errors.New("collector: interval must be positive")
+	}
+
+	saved, err := collections.Collections()
+
+	if err != nil {
+		return nil, err
+	}
+
+	collector := &Collector{
+		service:     service,
+		tweets:      tweets,
+		collections: collections,
+		interval:    interval,
+		nowImpl:     time.Now,
+		accounts:    make(map[string]*account),
+	}
+
+	for _, collection := range saved {
+		collector.add(collection)
+	}
+
+	return collector, nil
+}
+
+// Start collects in a background goroutine until Stop is called, starting a
+// running Collector does nothing
+func (collector *Collector) Start() {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+
+	if collector.stop != nil {
+		return
+	}
+
+	collector.stop = make(chan struct{})
+	collector.done = make(chan struct{})
+
+	go collector.run(collector.stop, collector.done)
+}
+
+// Stop cancels the collection in progress (if any) and waits for it
+func (collector *Collector) Stop() {
+	collector.mutex.Lock()
+	stop, done := collector.stop, collector.done
+	collector.stop, collector.done = nil, nil
+	collector.mutex.Unlock()
+
+	if stop == nil {
+		return
+	}
+
+	close(stop)
+	<-done
+}
+
+// OptIn adds the account of accessToken, it's collected on the next tick
+func (collector *Collector) OptIn(accessToken, accessSecret string) error {
+	if accessToken == "" || accessSecret == "" {
+		return &entities.UnauthenticatedError{
+			Reason: "accessToken or accessSecret missing -_-",
+		}
+	}
+
+	collection := storage.Collection{
+		Account:      services.AccountKey(accessToken),
+		AccessToken:  accessToken,
+		AccessSecret: accessSecret,
+	}
+
+	if err := collector.collections.SaveCollection(collection); err != nil {
+		return err
+	}
+
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+
+	collector.add(collection)
+	return nil
+}
+
+// OptOut stops collecting the account of accessToken, its stored tweets are
+// kept
+func (collector *Collector) OptOut(accessToken string) error {
+	account := services.AccountKey(accessToken)
+
+	if err := collector.collections.DeleteCollection(account); err != nil {
+		return err
+	}
+
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+
+	delete(collector.accounts, account)
+	return nil
+}
+
+// AccountStatus returns ErrNotOptedIn when accessToken's account isn't
+// collected
+func (collector *Collector) AccountStatus(accessToken string) (
+	AccountStatus, error,
+) {
+
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+
+	account, ok := collector.accounts[services.AccountKey(accessToken)]
+
+	if !ok {
+		return AccountStatus{}, ErrNotOptedIn
+	}
+
+	return account.status, nil
+}
+
+// Status blablabla
+func (collector *Collector) Status() Status {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+
+	status := Status{
+		Running:  collector.stop != nil,
+		Interval: collector.interval.String(),
+		Accounts: make([]AccountStatus, 0, len(collector.accounts)),
+	}
+
+	for _, account := range collector.accounts {
+		status.Accounts = append(status.Accounts, account.status)
+	}
+
+	return status
+}
+
+// add is called with mutex held (or before the Collector is shared)
+func (collector *Collector) add(collection storage.Collection) {
+	collector.accounts[collection.Account] = &account{
+		status: AccountStatus{
+			Account:   collection.Account,
+			NextRunAt: collector.nowImpl(),
+		},
+		collection: collection,
+	}
+}
+
+func (collector *Collector) run(stop, done chan struct{}) {
+	defer close(done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// checking more often than interval lets backoffs and rate limit resets
+	// end between ticks
+	tick := collector.interval
+	if tick > time.Minute {
+		tick = time.Minute
+	}
+
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		collector.collectDue(ctx)
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// collectDue fetches every account whose NextRunAt has come, one at a time so
+// Twitter isn't hit by all accounts at once
+func (collector *Collector) collectDue(ctx context.Context) {
+	collector.mutex.Lock()
+	now := collector.nowImpl()
+	due := []storage.Collection{}
+
+	for _, account := range collector.accounts {
+		if !now.Before(account.status.NextRunAt) {
+			due = append(due, account.collection)
+		}
+	}
+	collector.mutex.Unlock()
+
+	for _, collection := range due {
+		if ctx.Err() != nil {
+			return
+		}
+
+		added, err := collector.collect(ctx, collection)
+
+		// cancelled by Stop, it's not the account's fault
+		if ctx.Err() != nil {
+			return
+		}
+
+		collector.record(collection.Account, added, err)
+	}
+}
+
+func (collector *Collector) collect(
+	ctx context.Context,
+	collection storage.Collection,
+) (int, error) {
+
+	sinceID, err := collector.tweets.LatestID(collection.Account)
+
+	if err != nil {
+		return 0, err
+	}
+
+	timeline, err := collector.service.TweetersSince(
+		ctx,
+		collection.AccessToken,
+		collection.AccessSecret,
+		sinceID,
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	// the tweets between sinceID and the oldest fetched one are missing, and
+	// saving the newer ones would skip them for good (LatestID would be past
+	// them), so it's retried (after the reset when it's known) instead
+	if timeline.Truncated {
+		rateLimitedErr := &entities.RateLimitedError{}
+
+		if timeline.RateLimit != nil {
+			rateLimitedErr.Reset = timeline.RateLimit.Reset
+		}
+
+		return 0, rateLimitedErr
+	}
+
+	if sinceID != 0 && !timeline.ReachedSinceID {
+		return 0, errors.New("collector: timeline stopped before the newest stored tweet")
+	}
+
+	return collector.tweets.SaveTweets(collection.Account, timeline.Tweeters)
+}
+
+// record schedules the next collection of account, rate limited accounts wait
+// for the reset rather than backing off
+func (collector *Collector) record(account string, added int, err error) {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+
+	collected, ok := collector.accounts[account]
+
+	// opted out while being collected
+	if !ok {
+		return
+	}
+
+	status := &collected.status
+	now := collector.nowImpl()
+	status.LastRunAt = &now
+	status.LastAdded = added
+	status.LastError = ""
+
+	if err == nil {
+		status.Failures = 0
+		status.NextRunAt = now.Add(collector.interval)
+		return
+	}
+
+	status.LastError = err.Error()
+
+	if rateLimitedErr, ok := err.(*entities.RateLimitedError); ok &&
+		rateLimitedErr.Reset.After(now) {
+		status.NextRunAt = rateLimitedErr.Reset
+		return
+	}
+
+	status.Failures++
+	status.NextRunAt = now.Add(backoff(collector.interval, status.Failures))
+}
+
+func backoff(interval time.Duration, failures int) time.Duration {
+	delay := interval
+
+	for i := 1; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > maxBackoff {
+		return maxBackoff
+	}
+
+	return delay
+}
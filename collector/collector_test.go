@@ -0,0 +1,300 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/Ahimta/tweeters-stats-golang/services"
+	"github.com/Ahimta/tweeters-stats-golang/storage"
+)
+
+type fakeTweetsService struct {
+	sinceIDs  []int64
+	tweeters  []*entities.Tweeter
+	rateLimit *entities.RateLimit
+	truncated bool
+	gap       bool
+	err       error
+}
+
+func (service *fakeTweetsService) Tweeters(
+	ctx context.Context,
+	accessToken,
+	accessSecret string,
+) (*entities.Timeline, error) {
+
+	return service.TweetersSince(ctx, accessToken, accessSecret, 0)
+}
+
+func (service *fakeTweetsService) TweetersSince(
+	ctx context.Context,
+	accessToken,
+	accessSecret string,
+	sinceID int64,
+) (*entities.Timeline, error) {
+
+	service.sinceIDs = append(service.sinceIDs, sinceID)
+
+	if service.err != nil {
+		return nil, service.err
+	}
+
+	return &entities.Timeline{
+		Tweeters:  service.tweeters,
+		RateLimit: service.rateLimit,
+		Truncated: service.truncated,
+
+		ReachedSinceID: !service.gap,
+	}, nil
+}
+
+func (service *fakeTweetsService) RateLimit(accessToken string) (
+	entities.RateLimit, bool,
+) {
+
+	return entities.RateLimit{}, false
+}
+
+type fakeStore struct {
+	latestID    int64
+	saved       int
+	collections map[string]storage.Collection
+}
+
+func (store *fakeStore) SaveTweets(
+	account string,
+	tweets []*entities.Tweeter,
+) (int, error) {
+
+	store.saved += len(tweets)
+	return len(tweets), nil
+}
+
+func (store *fakeStore) Tweets(account string) ([]*entities.Tweeter, error) {
+	return nil, nil
+}
+
+func (store *fakeStore) LatestID(account string) (int64, error) {
+	return store.latestID, nil
+}
+
+func (store *fakeStore) SaveCollection(collection storage.Collection) error {
+	store.collections[collection.Account] = collection
+	return nil
+}
+
+func (store *fakeStore) DeleteCollection(account string) error {
+	delete(store.collections, account)
+	return nil
+}
+
+func (store *fakeStore) Collections() ([]storage.Collection, error) {
+	collections := []storage.Collection{}
+
+	for _, collection := range store.collections {
+		collections = append(collections, collection)
+	}
+
+	return collections, nil
+}
+
+func TestCollector(t *testing.T) {
+	now := time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC)
+	service := &fakeTweetsService{
+		tweeters: []*entities.Tweeter{{ID: 43}, {ID: 42}},
+	}
+	store := &fakeStore{
+		latestID:    41,
+		collections: make(map[string]storage.Collection),
+	}
+
+	collector, err := New(service, store, store, 15*time.Minute)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collector.nowImpl = func() time.Time { return now }
+	ctx := context.Background()
+
+	//
+	if _, err := collector.AccountStatus("accessToken"); err != ErrNotOptedIn {
+		t.Errorf("Should return ErrNotOptedIn before opting in: %v", err)
+	}
+
+	if err := collector.OptIn("accessToken", ""); err == nil {
+		t.Errorf("Should return an error when a token is missing")
+	}
+
+	if err := collector.OptIn("accessToken", "accessSecret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := store.collections[services.AccountKey("accessToken")]; !ok {
+		t.Errorf("Should save opted-in accounts")
+	}
+
+	//
+	collector.collectDue(ctx)
+	status, err := collector.AccountStatus("accessToken")
+
+	if err != nil ||
+		len(service.sinceIDs) != 1 ||
+		service.sinceIDs[0] != 41 ||
+		store.saved != 2 ||
+		status.LastAdded != 2 ||
+		!status.NextRunAt.Equal(now.Add(15*time.Minute)) {
+		t.Errorf("Should fetch tweets since the newest stored one: %v, %v", status, err)
+	}
+
+	//
+	collector.collectDue(ctx)
+
+	if len(service.sinceIDs) != 1 {
+		t.Errorf("Should not fetch accounts before they're due")
+	}
+
+	//
+	service.err = errors.New("Whaaat!")
+
+	for failures := 1; failures <= 2; failures++ {
+		now = now.Add(time.Hour)
+		collector.collectDue(ctx)
+		status, _ = collector.AccountStatus("accessToken")
+		wantNextRunAt := now.Add(backoff(15*time.Minute, failures))
+
+		if status.Failures != failures ||
+			status.LastError != "Whaaat!" ||
+			!status.NextRunAt.Equal(wantNextRunAt) {
+			t.Errorf("Should back off from failing accounts: %v", status)
+		}
+	}
+
+	//
+	now = now.Add(time.Hour)
+	reset := now.Add(10 * time.Minute)
+	service.err = &entities.RateLimitedError{Reset: reset}
+	collector.collectDue(ctx)
+	status, _ = collector.AccountStatus("accessToken")
+
+	if !status.NextRunAt.Equal(reset) {
+		t.Errorf("Should wait for rate limits to reset: %v", status)
+	}
+
+	//
+	service.err = nil
+	now = reset
+	collector.collectDue(ctx)
+	status, _ = collector.AccountStatus("accessToken")
+
+	if status.Failures != 0 || status.LastError != "" {
+		t.Errorf("Should reset failures after a success: %v", status)
+	}
+
+	//
+	now = now.Add(time.Hour)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	collector.collectDue(cancelled)
+
+	if len(service.sinceIDs) != 5 {
+		t.Errorf("Should not collect once cancelled: %v", service.sinceIDs)
+	}
+
+	//
+	reset = now.Add(10 * time.Minute)
+	service.rateLimit = &entities.RateLimit{Reset: reset}
+	service.truncated = true
+	saved := store.saved
+	collector.collectDue(ctx)
+	status, _ = collector.AccountStatus("accessToken")
+
+	if store.saved != saved || !status.NextRunAt.Equal(reset) {
+		t.Errorf("Should retry truncated timelines after the reset: %v", status)
+	}
+
+	//
+	now = reset.Add(time.Hour)
+	service.rateLimit = nil
+	collector.collectDue(ctx)
+	status, _ = collector.AccountStatus("accessToken")
+
+	if store.saved != saved || status.LastError == "" {
+		t.Errorf("Should retry truncated timelines without a rate limit: %v", status)
+	}
+
+	//
+	now = now.Add(time.Hour)
+	service.truncated = false
+	service.gap = true
+	collector.collectDue(ctx)
+	status, _ = collector.AccountStatus("accessToken")
+
+	if store.saved != saved || status.LastError == "" {
+		t.Errorf("Should retry timelines stopping before sinceID: %v", status)
+	}
+
+	service.gap = false
+
+	//
+	reloaded, err := New(service, store, store, 15*time.Minute)
+
+	if err != nil || len(reloaded.Status().Accounts) != 1 {
+		t.Errorf("Should load saved accounts: %v", err)
+	}
+
+	//
+	if err := collector.OptOut("accessToken"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := collector.AccountStatus("accessToken"); err != ErrNotOptedIn ||
+		len(store.collections) != 0 {
+		t.Errorf("Should forget opted-out accounts: %v", err)
+	}
+}
+
+func TestCollector_StartStop(t *testing.T) {
+	store := &fakeStore{collections: make(map[string]storage.Collection)}
+	collector, err := New(&fakeTweetsService{}, store, store, time.Hour)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//
+	collector.Start()
+	collector.Start()
+
+	if !collector.Status().Running {
+		t.Errorf("Should be running after Start")
+	}
+
+	//
+	collector.Stop()
+	collector.Stop()
+
+	if collector.Status().Running {
+		t.Errorf("Should not be running after Stop")
+	}
+}
+
+func Test_backoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, time.Hour},
+		{2, 2 * time.Hour},
+		{3, 4 * time.Hour},
+		{10, maxBackoff},
+	}
+	for _, tt := range tests {
+		if got := backoff(time.Hour, tt.failures); got != tt.want {
+			t.Errorf("backoff(%v) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
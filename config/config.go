@@ -1,7 +1,47 @@
 package config
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// the number of tweets Twitter allows reading from the home timeline
+	defaultTimelineBudget = 800
+
+	// the same as auth.TwitterEndpoint(auth.DefaultTwitterURL) and
+	// services.DefaultAPIURL, config doesn't import them to stay dependency-free
+	defaultRequestTokenURL = "https://api.twitter.com/oauth/request_token"
+	defaultAuthorizeURL    = "https://api.twitter.com/oauth/authorize"
+	defaultAccessTokenURL  = "https://api.twitter.com/oauth/access_token"
+	defaultAPIURL          = "https://api.twitter.com/1.1/"
+
+	defaultSessionTTL = 30 * 24 * time.Hour
+
+	// Twitter allows a home timeline request per minute on average
+	defaultStatsCacheTTL = time.Minute
+
+	// Twitter allows 15 home timeline requests per 15 minutes, leaving most
+	// of them for visits
+	defaultCollectorInterval = 15 * time.Minute
+
+	// fetching a whole timeline budget takes a few seconds at most
+	defaultRequestTimeout = 30 * time.Second
+
+	// long enough to upload a Twitter archive
+	defaultReadTimeout = time.Minute
+
+	// longer than defaultRequestTimeout so stats (and their streams) aren't
+	// cut off while waiting for Twitter
+	defaultWriteTimeout = 2 * time.Minute
+
+	defaultIdleTimeout     = 2 * time.Minute
+	defaultShutdownTimeout = 30 * time.Second
 )
 
 // Config blablabla
@@ -16,41 +56,211 @@ type Config struct {
 	Protocol string
 
 	CorsDomain string
+
+	TimelineBudget uint
+
+	RequestTokenURL string
+	AuthorizeURL    string
+	AccessTokenURL  string
+	APIURL          string
+
+	// an empty SessionStorePath keeps sessions in memory
+	SessionStorePath string
+	SessionTTL       time.Duration
+
+	// zero disables caching timelines
+	StatsCacheTTL time.Duration
+
+	// an empty HistoryStorePath only computes stats over fetched timelines
+	HistoryStorePath string
+
+	// collecting needs a HistoryStorePath, zero disables it
+	CollectorInterval time.Duration
+
+	// an empty AdminToken disables admin endpoints
+	AdminToken string
+
+	// how long a request can wait for Twitter, zero means no deadline
+	RequestTimeout time.Duration
+
+	// server timeouts, zero means no timeout, WriteTimeout has to be longer
+	// than RequestTimeout
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	// how long in-flight requests have to finish on SIGINT or SIGTERM, zero
+	// means waiting for them however long they take
+	ShutdownTimeout time.Duration
+
+	// AES key sealing new cookies, cookies sealed with CookieOldKeys are still
+	// accepted so keys can be rotated without logging everyone out
+	CookieKey     []byte
+	CookieOldKeys [][]byte
 }
 
-// New blablabla
-func New(
-	consumerKey,
-	consumerSecret,
-	callbackURL,
-	port,
-	homepage,
-	host,
-	protocol,
-	corsDomain string) (
-	*Config, error,
-) {
-
-	if consumerKey == "" ||
-		consumerSecret == "" ||
-		callbackURL == "" ||
-		port == "" ||
-		homepage == "" ||
-		host == "" ||
-		protocol == "" {
+// New reads the config using getenv (e.g., os.Getenv)
+func New(getenv func(key string) string) (*Config, error) {
+	c := &Config{
+		ConsumerKey:    getenv("CONSUMER_KEY"),
+		ConsumerSecret: getenv("CONSUMER_SECRET"),
+		CallbackURL:    getenv("CALLBACK_URL"),
+		Port:           getenv("PORT"),
+		Homepage:       getenv("HOMEPAGE"),
+
+		Host:     getenv("HOST"),
+		Protocol: getenv("PROTOCOL"),
+
+		CorsDomain: getenv("CORS_DOMAIN"),
+
+		SessionStorePath: getenv("SESSION_STORE_PATH"),
+		SessionTTL:       defaultSessionTTL,
+
+		StatsCacheTTL:     defaultStatsCacheTTL,
+		HistoryStorePath:  getenv("HISTORY_STORE_PATH"),
+		CollectorInterval: defaultCollectorInterval,
+		AdminToken:        getenv("ADMIN_TOKEN"),
+		RequestTimeout:    defaultRequestTimeout,
+		ReadTimeout:       defaultReadTimeout,
+		WriteTimeout:      defaultWriteTimeout,
+		IdleTimeout:       defaultIdleTimeout,
+		ShutdownTimeout:   defaultShutdownTimeout,
+	}
+
+	if c.ConsumerKey == "" ||
+		c.ConsumerSecret == "" ||
+		c.CallbackURL == "" ||
+		c.Port == "" ||
+		c.Homepage == "" ||
+		c.Host == "" ||
+		c.Protocol == "" {
 		return nil, errors.New("config: a required parameter is missing -_-")
 	}
 
-	return &Config{
-		consumerKey,
-		consumerSecret,
-		callbackURL,
-		port,
-		homepage,
+	budget := uint64(defaultTimelineBudget)
+	if timelineBudget := getenv("TIMELINE_BUDGET"); timelineBudget != "" {
+		var err error
+		budget, err = strconv.ParseUint(timelineBudget, 10, 32)
+
+		if err != nil || budget == 0 {
+			return nil, errors.New("config: timelineBudget must be a positive integer")
+		}
+	}
+
+	c.TimelineBudget = uint(budget)
+
+	for _, setting := range []struct {
+		key          string
+		defaultValue string
+		value        *string
+	}{
+		{"OAUTH_REQUEST_TOKEN_URL", defaultRequestTokenURL, &c.RequestTokenURL},
+		{"OAUTH_AUTHORIZE_URL", defaultAuthorizeURL, &c.AuthorizeURL},
+		{"OAUTH_ACCESS_TOKEN_URL", defaultAccessTokenURL, &c.AccessTokenURL},
+		{"TWITTER_API_URL", defaultAPIURL, &c.APIURL},
+	} {
+		value := getenv(setting.key)
+		if value == "" {
+			value = setting.defaultValue
+		}
+
+		if !isHTTPURL(value) {
+			return nil, fmt.Errorf("config: %s must be an http(s) URL", setting.key)
+		}
+
+		*setting.value = value
+	}
+
+	for _, setting := range []struct {
+		key      string
+		positive bool
+		value    *time.Duration
+	}{
+		{"SESSION_TTL", true, &c.SessionTTL},
+		{"STATS_CACHE_TTL", false, &c.StatsCacheTTL},
+		{"COLLECTOR_INTERVAL", false, &c.CollectorInterval},
+		{"REQUEST_TIMEOUT", false, &c.RequestTimeout},
+		{"READ_TIMEOUT", false, &c.ReadTimeout},
+		{"WRITE_TIMEOUT", false, &c.WriteTimeout},
+		{"IDLE_TIMEOUT", false, &c.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", false, &c.ShutdownTimeout},
+	} {
+		value := getenv(setting.key)
+		if value == "" {
+			continue
+		}
+
+		duration, err := time.ParseDuration(value)
+
+		if setting.positive && (err != nil || duration <= 0) {
+			return nil, fmt.Errorf(
+				"config: %s must be a positive duration",
+				setting.key,
+			)
+		}
+
+		if err != nil || duration < 0 {
+			return nil, fmt.Errorf(
+				"config: %s must be a non-negative duration",
+				setting.key,
+			)
+		}
+
+		*setting.value = duration
+	}
+
+	if c.WriteTimeout > 0 &&
+		(c.RequestTimeout == 0 || c.WriteTimeout <= c.RequestTimeout) {
+		return nil, errors.New(
+			"config: WRITE_TIMEOUT must be longer than REQUEST_TIMEOUT",
+		)
+	}
+
+	cookieKey, err := parseKey(getenv("COOKIE_KEY"))
+
+	if err != nil {
+		return nil, errors.New("config: COOKIE_KEY must be a base64 AES key")
+	}
+
+	c.CookieKey = cookieKey
+
+	if oldKeys := getenv("COOKIE_OLD_KEYS"); oldKeys != "" {
+		for _, oldKey := range strings.Split(oldKeys, ",") {
+			key, err := parseKey(strings.TrimSpace(oldKey))
+
+			if err != nil {
+				return nil, errors.New(
+					"config: COOKIE_OLD_KEYS must be comma-separated base64 AES keys",
+				)
+			}
+
+			c.CookieOldKeys = append(c.CookieOldKeys, key)
+		}
+	}
+
+	return c, nil
+}
+
+// parseKey decodes a base64 AES-128, AES-192 or AES-256 key
+func parseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	}
+
+	return nil, errors.New("config: invalid key size")
+}
 
-		host,
-		protocol,
+func isHTTPURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
 
-		corsDomain,
-	}, nil
+	return err == nil &&
+		(u.Scheme == "http" || u.Scheme == "https") &&
+		u.Host != ""
 }
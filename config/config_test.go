@@ -3,98 +3,314 @@ package config
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestNew(t *testing.T) {
-	type args struct {
-		consumerKey    string
-		consumerSecret string
-		callbackURL    string
-		port           string
-		homepage       string
-		host           string
-		protocol       string
-		corsDomain     string
-	}
 	tests := []struct {
 		name    string
-		args    args
+		env     map[string]string
 		want    *Config
 		wantErr bool
 	}{
 		{
 			name: "should return a valid value when all args are provided",
-			args: args{
-				"consumerKey",
-				"consumerSecret",
-				"callbackURL",
-				"8",
-				"/",
-				"d",
-				"h",
-				"p",
+			env: map[string]string{
+				"CONSUMER_KEY":            "consumerKey",
+				"CONSUMER_SECRET":         "consumerSecret",
+				"CALLBACK_URL":            "callbackURL",
+				"PORT":                    "8",
+				"HOMEPAGE":                "/",
+				"HOST":                    "d",
+				"PROTOCOL":                "h",
+				"CORS_DOMAIN":             "p",
+				"TIMELINE_BUDGET":         "400",
+				"OAUTH_REQUEST_TOKEN_URL": "http://localhost:8081/oauth/request_token",
+				"OAUTH_AUTHORIZE_URL":     "http://localhost:8081/oauth/authorize",
+				"OAUTH_ACCESS_TOKEN_URL":  "http://localhost:8081/oauth/access_token",
+				"TWITTER_API_URL":         "http://localhost:8081/1.1/",
+				"SESSION_STORE_PATH":      "sessions.db",
+				"SESSION_TTL":             "24h",
+				"STATS_CACHE_TTL":         "0s",
+				"HISTORY_STORE_PATH":      "history.db",
+				"COLLECTOR_INTERVAL":      "1h",
+				"ADMIN_TOKEN":             "adminToken",
+				"REQUEST_TIMEOUT":         "10s",
+				"READ_TIMEOUT":            "5s",
+				"WRITE_TIMEOUT":           "15s",
+				"IDLE_TIMEOUT":            "0s",
+				"SHUTDOWN_TIMEOUT":        "20s",
+				"COOKIE_KEY":              "a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s=",
+				"COOKIE_OLD_KEYS":         "b29vb29vb29vb29vb29vbw==, a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s=",
 			},
 			want: &Config{
-				"consumerKey",
-				"consumerSecret",
-				"callbackURL",
-				"8",
-				"/",
-				"d",
-				"h",
-				"p",
+				ConsumerKey:     "consumerKey",
+				ConsumerSecret:  "consumerSecret",
+				CallbackURL:     "callbackURL",
+				Port:            "8",
+				Homepage:        "/",
+				Host:            "d",
+				Protocol:        "h",
+				CorsDomain:      "p",
+				TimelineBudget:  400,
+				RequestTokenURL: "http://localhost:8081/oauth/request_token",
+				AuthorizeURL:    "http://localhost:8081/oauth/authorize",
+				AccessTokenURL:  "http://localhost:8081/oauth/access_token",
+				APIURL:          "http://localhost:8081/1.1/",
+
+				SessionStorePath: "sessions.db",
+				SessionTTL:       24 * time.Hour,
+				StatsCacheTTL:    0,
+				HistoryStorePath: "history.db",
+
+				CollectorInterval: time.Hour,
+				AdminToken:        "adminToken",
+				RequestTimeout:    10 * time.Second,
+				ReadTimeout:       5 * time.Second,
+				WriteTimeout:      15 * time.Second,
+				IdleTimeout:       0,
+				ShutdownTimeout:   20 * time.Second,
+
+				CookieKey: []byte("kkkkkkkkkkkkkkkkkkkkkkkkkkkkkkkk"),
+				CookieOldKeys: [][]byte{
+					[]byte("oooooooooooooooo"),
+					[]byte("kkkkkkkkkkkkkkkkkkkkkkkkkkkkkkkk"),
+				},
 			},
 		},
 		{
-			name: "should return a valid value when corsDomain is missing",
-			args: args{
-				"consumerKey",
-				"consumerSecret",
-				"callbackURL",
-				"80",
-				"/",
-				"h",
-				"p",
-				"",
+			name: "should use defaults when optional args are missing",
+			env: map[string]string{
+				"CONSUMER_KEY":    "consumerKey",
+				"CONSUMER_SECRET": "consumerSecret",
+				"CALLBACK_URL":    "callbackURL",
+				"PORT":            "80",
+				"HOMEPAGE":        "/",
+				"HOST":            "h",
+				"PROTOCOL":        "p",
+				"COOKIE_KEY":      "a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s=",
 			},
 			want: &Config{
-				"consumerKey",
-				"consumerSecret",
-				"callbackURL",
-				"80",
-				"/",
-				"h",
-				"p",
-				"",
+				ConsumerKey:     "consumerKey",
+				ConsumerSecret:  "consumerSecret",
+				CallbackURL:     "callbackURL",
+				Port:            "80",
+				Homepage:        "/",
+				Host:            "h",
+				Protocol:        "p",
+				TimelineBudget:  800,
+				RequestTokenURL: "https://api.twitter.com/oauth/request_token",
+				AuthorizeURL:    "https://api.twitter.com/oauth/authorize",
+				AccessTokenURL:  "https://api.twitter.com/oauth/access_token",
+				APIURL:          "https://api.twitter.com/1.1/",
+
+				SessionTTL:        30 * 24 * time.Hour,
+				StatsCacheTTL:     time.Minute,
+				CollectorInterval: 15 * time.Minute,
+				RequestTimeout:    30 * time.Second,
+				ReadTimeout:       time.Minute,
+				WriteTimeout:      2 * time.Minute,
+				IdleTimeout:       2 * time.Minute,
+				ShutdownTimeout:   30 * time.Second,
+
+				CookieKey: []byte("kkkkkkkkkkkkkkkkkkkkkkkkkkkkkkkk"),
 			},
 		},
 		{
 			name: "should return an error when a parameter value is missing",
-			args: args{
-				"consumerKey",
-				"consumerSecret",
-				"callbackURL",
-				"80",
-				"/",
-				"h",
-				"",
-				"",
+			env: map[string]string{
+				"CONSUMER_KEY":    "consumerKey",
+				"CONSUMER_SECRET": "consumerSecret",
+				"CALLBACK_URL":    "callbackURL",
+				"PORT":            "80",
+				"HOMEPAGE":        "/",
+				"HOST":            "h",
+				"COOKIE_KEY":      "a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s=",
+			},
+			wantErr: true,
+		},
+		{
+			name: "should return an error when timelineBudget is invalid",
+			env: map[string]string{
+				"CONSUMER_KEY":    "consumerKey",
+				"CONSUMER_SECRET": "consumerSecret",
+				"CALLBACK_URL":    "callbackURL",
+				"PORT":            "80",
+				"HOMEPAGE":        "/",
+				"HOST":            "h",
+				"PROTOCOL":        "p",
+				"TIMELINE_BUDGET": "0",
+				"COOKIE_KEY":      "a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s=",
+			},
+			wantErr: true,
+		},
+		{
+			name: "should return an error when SESSION_TTL is invalid",
+			env: map[string]string{
+				"CONSUMER_KEY":    "consumerKey",
+				"CONSUMER_SECRET": "consumerSecret",
+				"CALLBACK_URL":    "callbackURL",
+				"PORT":            "80",
+				"HOMEPAGE":        "/",
+				"HOST":            "h",
+				"PROTOCOL":        "p",
+				"SESSION_TTL":     "forever",
+				"COOKIE_KEY":      "a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s=",
+			},
+			wantErr: true,
+		},
+		{
+			name: "should return an error when SESSION_TTL is zero",
+			env: map[string]string{
+				"CONSUMER_KEY":    "consumerKey",
+				"CONSUMER_SECRET": "consumerSecret",
+				"CALLBACK_URL":    "callbackURL",
+				"PORT":            "80",
+				"HOMEPAGE":        "/",
+				"HOST":            "h",
+				"PROTOCOL":        "p",
+				"SESSION_TTL":     "0s",
+				"COOKIE_KEY":      "a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s=",
+			},
+			wantErr: true,
+		},
+		{
+			name: "should return an error when STATS_CACHE_TTL is invalid",
+			env: map[string]string{
+				"CONSUMER_KEY":    "consumerKey",
+				"CONSUMER_SECRET": "consumerSecret",
+				"CALLBACK_URL":    "callbackURL",
+				"PORT":            "80",
+				"HOMEPAGE":        "/",
+				"HOST":            "h",
+				"PROTOCOL":        "p",
+				"COOKIE_KEY":      "a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s=",
+				"STATS_CACHE_TTL": "-1m",
+			},
+			wantErr: true,
+		},
+		{
+			name: "should return an error when COLLECTOR_INTERVAL is invalid",
+			env: map[string]string{
+				"CONSUMER_KEY":       "consumerKey",
+				"CONSUMER_SECRET":    "consumerSecret",
+				"CALLBACK_URL":       "callbackURL",
+				"PORT":               "80",
+				"HOMEPAGE":           "/",
+				"HOST":               "h",
+				"PROTOCOL":           "p",
+				"COOKIE_KEY":         "a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s=",
+				"COLLECTOR_INTERVAL": "often",
+			},
+			wantErr: true,
+		},
+		{
+			name: "should return an error when REQUEST_TIMEOUT is invalid",
+			env: map[string]string{
+				"CONSUMER_KEY":    "consumerKey",
+				"CONSUMER_SECRET": "consumerSecret",
+				"CALLBACK_URL":    "callbackURL",
+				"PORT":            "80",
+				"HOMEPAGE":        "/",
+				"HOST":            "h",
+				"PROTOCOL":        "p",
+				"COOKIE_KEY":      "a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s=",
+				"REQUEST_TIMEOUT": "-1s",
+			},
+			wantErr: true,
+		},
+		{
+			name: "should return an error when SHUTDOWN_TIMEOUT is invalid",
+			env: map[string]string{
+				"CONSUMER_KEY":     "consumerKey",
+				"CONSUMER_SECRET":  "consumerSecret",
+				"CALLBACK_URL":     "callbackURL",
+				"PORT":             "80",
+				"HOMEPAGE":         "/",
+				"HOST":             "h",
+				"PROTOCOL":         "p",
+				"COOKIE_KEY":       "a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s=",
+				"SHUTDOWN_TIMEOUT": "soon",
+			},
+			wantErr: true,
+		},
+		{
+			name: "should return an error when WRITE_TIMEOUT cuts off requests",
+			env: map[string]string{
+				"CONSUMER_KEY":    "consumerKey",
+				"CONSUMER_SECRET": "consumerSecret",
+				"CALLBACK_URL":    "callbackURL",
+				"PORT":            "80",
+				"HOMEPAGE":        "/",
+				"HOST":            "h",
+				"PROTOCOL":        "p",
+				"COOKIE_KEY":      "a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s=",
+				"REQUEST_TIMEOUT": "30s",
+				"WRITE_TIMEOUT":   "10s",
+			},
+			wantErr: true,
+		},
+		{
+			name: "should return an error when a Twitter URL is invalid",
+			env: map[string]string{
+				"CONSUMER_KEY":    "consumerKey",
+				"CONSUMER_SECRET": "consumerSecret",
+				"CALLBACK_URL":    "callbackURL",
+				"PORT":            "80",
+				"HOMEPAGE":        "/",
+				"HOST":            "h",
+				"PROTOCOL":        "p",
+				"TWITTER_API_URL": "localhost:8081/1.1/",
+				"COOKIE_KEY":      "a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s=",
+			},
+			wantErr: true,
+		},
+		{
+			name: "should return an error when COOKIE_KEY is missing",
+			env: map[string]string{
+				"CONSUMER_KEY":    "consumerKey",
+				"CONSUMER_SECRET": "consumerSecret",
+				"CALLBACK_URL":    "callbackURL",
+				"PORT":            "80",
+				"HOMEPAGE":        "/",
+				"HOST":            "h",
+				"PROTOCOL":        "p",
+			},
+			wantErr: true,
+		},
+		{
+			name: "should return an error when COOKIE_KEY has an invalid size",
+			env: map[string]string{
+				"CONSUMER_KEY":    "consumerKey",
+				"CONSUMER_SECRET": "consumerSecret",
+				"CALLBACK_URL":    "callbackURL",
+				"PORT":            "80",
+				"HOMEPAGE":        "/",
+				"HOST":            "h",
+				"PROTOCOL":        "p",
+				"COOKIE_KEY":      "a2tr",
+			},
+			wantErr: true,
+		},
+		{
+			name: "should return an error when COOKIE_OLD_KEYS is invalid",
+			env: map[string]string{
+				"CONSUMER_KEY":    "consumerKey",
+				"CONSUMER_SECRET": "consumerSecret",
+				"CALLBACK_URL":    "callbackURL",
+				"PORT":            "80",
+				"HOMEPAGE":        "/",
+				"HOST":            "h",
+				"PROTOCOL":        "p",
+				"COOKIE_KEY":      "a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s=",
+				"COOKIE_OLD_KEYS": "b29vb29vb29vb29vb29vbw==,",
 			},
 			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := New(
-				tt.args.consumerKey,
-				tt.args.consumerSecret,
-				tt.args.callbackURL,
-				tt.args.port,
-				tt.args.homepage,
-				tt.args.host,
-				tt.args.protocol,
-				tt.args.corsDomain,
-			)
+			got, err := New(func(key string) string { return tt.env[key] })
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewConfig() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -1,15 +1,208 @@
 package entities
 
+import "time"
+
+// TweetType blablabla
+type TweetType string
+
+// a tweet has exactly one type, when more than one applies (e.g., a reply
+// that quotes another tweet) the first one in this list wins
+const (
+	Retweet       TweetType = "retweet"
+	Reply         TweetType = "reply"
+	Quote         TweetType = "quote"
+	OriginalTweet TweetType = "original"
+)
+
 // TweeterStats blablabla
 type TweeterStats struct {
 	FullName string `json:"fullName"`
 	Username string `json:"username"`
 
-	TweetsCount uint `json:"tweetsCount"`
+	TweetsCount   uint `json:"tweetsCount"`
+	OriginalCount uint `json:"originalCount"`
+	RetweetCount  uint `json:"retweetCount"`
+	ReplyCount    uint `json:"replyCount"`
+	QuoteCount    uint `json:"quoteCount"`
+
+	// percentages of the tweets in the stats, the cumulative one adds up the
+	// tweeters ranked above this one by tweets count
+	Percent           float64 `json:"percent"`
+	CumulativePercent float64 `json:"cumulativePercent"`
+}
+
+// Count blablabla
+func (stats *TweeterStats) Count(tweetType TweetType) {
+	stats.TweetsCount++
+
+	switch tweetType {
+	case Retweet:
+		stats.RetweetCount++
+	case Reply:
+		stats.ReplyCount++
+	case Quote:
+		stats.QuoteCount++
+	default:
+		stats.OriginalCount++
+	}
+}
+
+// StatsSummary is how concentrated a timeline is among its tweeters, the
+// higher Gini and Herfindahl are the more a few tweeters dominate it
+type StatsSummary struct {
+	TweetsCount   uint `json:"tweetsCount"`
+	TweetersCount uint `json:"tweetersCount"`
+
+	Gini       float64 `json:"gini"`
+	Herfindahl float64 `json:"herfindahl"`
+
+	// the percentage of tweets by the top 10% of tweeters (at least one)
+	TopTenthPercent float64 `json:"topTenthPercent"`
 }
 
 // Tweeter blablabla
 type Tweeter struct {
+	// the tweet's ID
+	ID int64
+
+	FullName string
+	Username string
+
+	CreatedAt time.Time
+	Type      TweetType
+
+	// the original author when Type is Retweet
+	RetweetedFullName string
+	RetweetedUsername string
+
+	// the replied-to author when Type is Reply (may be empty when unknown)
+	InReplyToUsername string
+
+	// the tweet's entities (the original tweet's for retweets), hashtags are
+	// without the leading # and URLs are expanded
+	Hashtags []string
+	Mentions []Mention
+	URLs     []string
+}
+
+// Mention is a user mentioned in a tweet
+type Mention struct {
 	FullName string
 	Username string
 }
+
+// EntityStats counts the tweets (and distinct tweeters) using a hashtag,
+// mentioning a user or linking to a domain
+type EntityStats struct {
+	Name string `json:"name"`
+
+	// only set for mentioned users
+	FullName string `json:"fullName,omitempty"`
+
+	TweetsCount   uint `json:"tweetsCount"`
+	TweetersCount uint `json:"tweetersCount"`
+}
+
+// AmplifiedStats counts how often an author reaches the timeline through
+// other tweeters' retweets
+type AmplifiedStats struct {
+	FullName string `json:"fullName"`
+	Username string `json:"username"`
+
+	RetweetsCount   uint `json:"retweetsCount"`
+	RetweetersCount uint `json:"retweetersCount"`
+}
+
+// TimeSeriesPoint is how many tweets were created in the bucket starting at
+// Time
+type TimeSeriesPoint struct {
+	Time  time.Time `json:"time"`
+	Count uint      `json:"count"`
+}
+
+// TweeterTimeSeries blablabla
+type TweeterTimeSeries struct {
+	FullName string `json:"fullName"`
+	Username string `json:"username"`
+
+	TweetsCount uint              `json:"tweetsCount"`
+	Points      []TimeSeriesPoint `json:"points"`
+}
+
+// Heatmap counts tweets by weekday (Sunday first) and hour of day
+type Heatmap [7][24]uint
+
+// Count blablabla
+func (heatmap *Heatmap) Count(t time.Time) {
+	heatmap[t.Weekday()][t.Hour()]++
+}
+
+// TweeterHeatmap blablabla
+type TweeterHeatmap struct {
+	FullName string `json:"fullName"`
+	Username string `json:"username"`
+
+	TweetsCount uint    `json:"tweetsCount"`
+	Heatmap     Heatmap `json:"heatmap"`
+}
+
+// GraphNode is a tweeter in an interaction graph, its ID is the lowercased
+// username
+type GraphNode struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	FullName string `json:"fullName,omitempty"`
+
+	// tweets by the tweeter in the timeline (zero for tweeters only
+	// interacted with)
+	TweetsCount uint `json:"tweetsCount"`
+}
+
+// GraphEdge is how many times Source interacted with Target, Weight is the
+// sum of the others
+type GraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+
+	Weight   uint `json:"weight"`
+	Replies  uint `json:"replies"`
+	Mentions uint `json:"mentions"`
+	Retweets uint `json:"retweets"`
+}
+
+// Graph is a weighted directed graph, nodes are sorted by ID and edges by
+// source then target
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// RateLimit is Twitter's home timeline rate limit for an access token
+type RateLimit struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// Timeline blablabla
+type Timeline struct {
+	Tweeters []*Tweeter
+
+	PagesCount  uint
+	TweetsCount uint
+
+	// nil when unknown (e.g., for archives)
+	RateLimit *RateLimit
+
+	// the rate limit ran out before the whole timeline (or budget) was
+	// fetched, so only its newest pages are there
+	Truncated bool
+
+	// the fetch went as far back as sinceID (or the timeline's end when
+	// there's none) rather than stopping at the budget or a rate limit
+	ReachedSinceID bool
+
+	// when the timeline was fetched from Twitter, zero when it's not cached
+	// (i.e., it was just fetched)
+	FetchedAt time.Time
+}
@@ -0,0 +1,71 @@
+package entities
+
+import (
+	"fmt"
+	"time"
+)
+
+// UnauthenticatedError is returned when an access token is missing or Twitter
+// rejects it
+type UnauthenticatedError struct {
+	Reason string
+}
+
+// Error blablabla
+func (err *UnauthenticatedError) Error() string {
+	return "unauthenticated: " + err.Reason
+}
+
+// RateLimitedError is returned when Twitter's rate limit is exhausted until
+// Reset (a zero Reset means Twitter didn't say)
+type RateLimitedError struct {
+	Reset time.Time
+}
+
+// Error blablabla
+func (err *RateLimitedError) Error() string {
+	if err.Reset.IsZero() {
+		return "rate limited"
+	}
+
+	return fmt.Sprintf("rate limited until %s", err.Reset.Format(time.RFC3339))
+}
+
+// UpstreamError is returned when Twitter can't be reached or responds with
+// something unexpected (e.g., a 5xx or invalid JSON)
+type UpstreamError struct {
+	Err error
+}
+
+// Error blablabla
+func (err *UpstreamError) Error() string {
+	return "upstream unavailable: " + err.Err.Error()
+}
+
+// TimeoutError is returned when a request's deadline passes before Twitter
+// answers
+type TimeoutError struct{}
+
+// Error blablabla
+func (err *TimeoutError) Error() string {
+	return "timed out"
+}
+
+// CanceledError is returned when a request is canceled before Twitter answers
+// (e.g., the client went away)
+type CanceledError struct{}
+
+// Error blablabla
+func (err *CanceledError) Error() string {
+	return "canceled"
+}
+
+// InvalidInputError blablabla
+type InvalidInputError struct {
+	Message string
+}
+
+// Error blablabla
+func (err *InvalidInputError) Error() string {
+	return "invalid input: " + err.Message
+}
@@ -0,0 +1,420 @@
+// Package faketwitter serves the parts of Twitter's API this project uses
+// (OAuth1 login and the home timeline) from seeded fixtures, so the whole
+// login-to-stats flow can run without network access
+package faketwitter
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/dghubble/oauth1"
+)
+
+// Fixtures seed a Server
+type Fixtures struct {
+	ConsumerKey    string
+	ConsumerSecret string
+
+	// the credentials issued to whoever completes the OAuth1 flow
+	AccessToken  string
+	AccessSecret string
+	ScreenName   string
+
+	// the home timeline of the account above (in any order)
+	Tweets []twitter.Tweet
+
+	// home timeline requests allowed per 15 minutes (default: 15, like Twitter)
+	TimelineRateLimit int
+}
+
+// the home timeline rate limit window
+const rateLimitWindow = 15 * time.Minute
+
+// Server is a fake Twitter API running on an httptest.Server
+type Server struct {
+	*httptest.Server
+
+	fixtures Fixtures
+
+	mutex         sync.Mutex
+	requestTokens map[string]*requestToken
+	timelineCalls int
+
+	rateLimitRemaining int
+	rateLimitReset     time.Time
+}
+
+type requestToken struct {
+	secret      string
+	callbackURL string
+	verifier    string
+}
+
+// NewServer starts a Server, callers should Close it when done
+func NewServer(fixtures Fixtures) *Server {
+	if fixtures.TimelineRateLimit == 0 {
+		fixtures.TimelineRateLimit = 15
+	}
+
+	server := &Server{
+		fixtures:      fixtures,
+		requestTokens: make(map[string]*requestToken),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/request_token", server.requestToken)
+	mux.HandleFunc("/oauth/authorize", server.authorize)
+	mux.HandleFunc("/oauth/access_token", server.accessToken)
+	mux.HandleFunc("/1.1/statuses/home_timeline.json", server.homeTimeline)
+
+	server.Server = httptest.NewServer(mux)
+	return server
+}
+
+// APIURL is the REST API base to use instead of services.DefaultAPIURL
+func (server *Server) APIURL() string {
+	return server.URL + "/1.1/"
+}
+
+// TimelineCalls is the number of home timeline requests served so far
+func (server *Server) TimelineCalls() int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	return server.timelineCalls
+}
+
+func (server *Server) requestToken(w http.ResponseWriter, r *http.Request) {
+	params, ok := server.verify(w, r, http.MethodPost, "")
+	if !ok {
+		return
+	}
+
+	callbackURL := params["oauth_callback"]
+	if callbackURL == "" {
+		writeError(w, http.StatusBadRequest, "missing oauth_callback")
+		return
+	}
+
+	token := &requestToken{secret: randomString(), callbackURL: callbackURL}
+	tokenValue := randomString()
+
+	server.mutex.Lock()
+	server.requestTokens[tokenValue] = token
+	server.mutex.Unlock()
+
+	fmt.Fprint(w, url.Values{
+		"oauth_token":              {tokenValue},
+		"oauth_token_secret":       {token.secret},
+		"oauth_callback_confirmed": {"true"},
+	}.Encode())
+}
+
+// authorize approves right away (as if the user clicked "Authorize app")
+func (server *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	tokenValue := r.URL.Query().Get("oauth_token")
+
+	var verifier string
+
+	server.mutex.Lock()
+	token, ok := server.requestTokens[tokenValue]
+
+	if ok {
+		token.verifier = randomString()
+		verifier = token.verifier
+	}
+
+	server.mutex.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "invalid oauth_token")
+		return
+	}
+
+	callbackURL, err := url.Parse(token.callbackURL)
+
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid oauth_callback")
+		return
+	}
+
+	query := callbackURL.Query()
+	query.Set("oauth_token", tokenValue)
+	query.Set("oauth_verifier", verifier)
+	callbackURL.RawQuery = query.Encode()
+
+	http.Redirect(w, r, callbackURL.String(), http.StatusFound)
+}
+
+func (server *Server) accessToken(w http.ResponseWriter, r *http.Request) {
+	tokenValue := oauthParams(r)["oauth_token"]
+
+	var verifier string
+
+	// authorize sets the verifier while holding the mutex
+	server.mutex.Lock()
+	token, ok := server.requestTokens[tokenValue]
+
+	if ok {
+		verifier = token.verifier
+	}
+
+	server.mutex.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "invalid oauth_token")
+		return
+	}
+
+	params, ok := server.verify(w, r, http.MethodPost, token.secret)
+	if !ok {
+		return
+	}
+
+	if verifier == "" || params["oauth_verifier"] != verifier {
+		writeError(w, http.StatusUnauthorized, "invalid oauth_verifier")
+		return
+	}
+
+	// request tokens can only be exchanged once
+	server.mutex.Lock()
+	delete(server.requestTokens, tokenValue)
+	server.mutex.Unlock()
+
+	fmt.Fprint(w, url.Values{
+		"oauth_token":        {server.fixtures.AccessToken},
+		"oauth_token_secret": {server.fixtures.AccessSecret},
+		"screen_name":        {server.fixtures.ScreenName},
+	}.Encode())
+}
+
+func (server *Server) homeTimeline(w http.ResponseWriter, r *http.Request) {
+	params, ok := server.verify(
+		w,
+		r,
+		http.MethodGet,
+		server.fixtures.AccessSecret,
+	)
+
+	if !ok {
+		return
+	}
+
+	if params["oauth_token"] != server.fixtures.AccessToken {
+		writeError(w, http.StatusUnauthorized, "invalid oauth_token")
+		return
+	}
+
+	if !server.takeTimelineCall(w) {
+		return
+	}
+
+	query := r.URL.Query()
+	count := 20
+	if value := query.Get("count"); value != "" {
+		count, _ = strconv.Atoi(value)
+	}
+
+	if count > 200 {
+		count = 200
+	}
+
+	maxID, _ := strconv.ParseInt(query.Get("max_id"), 10, 64)
+	sinceID, _ := strconv.ParseInt(query.Get("since_id"), 10, 64)
+
+	tweets := make([]twitter.Tweet, len(server.fixtures.Tweets))
+	copy(tweets, server.fixtures.Tweets)
+	sort.Slice(tweets, func(i, j int) bool { return tweets[i].ID > tweets[j].ID })
+
+	page := []twitter.Tweet{}
+	for _, tweet := range tweets {
+		if len(page) >= count {
+			break
+		}
+
+		if (maxID != 0 && tweet.ID > maxID) || tweet.ID <= sinceID {
+			continue
+		}
+
+		page = append(page, tweet)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// takeTimelineCall counts a home timeline call against the rate limit and
+// writes the x-rate-limit-* headers, it writes a 429 when the limit is
+// exhausted
+func (server *Server) takeTimelineCall(w http.ResponseWriter) bool {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.timelineCalls++
+	now := time.Now()
+
+	if !now.Before(server.rateLimitReset) {
+		server.rateLimitRemaining = server.fixtures.TimelineRateLimit
+		server.rateLimitReset = now.Add(rateLimitWindow)
+	}
+
+	allowed := server.rateLimitRemaining > 0
+	if allowed {
+		server.rateLimitRemaining--
+	}
+
+	limit := server.fixtures.TimelineRateLimit
+	reset := server.rateLimitReset.Unix()
+
+	header := w.Header()
+	header.Set("x-rate-limit-limit", strconv.Itoa(limit))
+	header.Set("x-rate-limit-remaining", strconv.Itoa(server.rateLimitRemaining))
+	header.Set("x-rate-limit-reset", strconv.FormatInt(reset, 10))
+
+	if !allowed {
+		writeError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+	}
+
+	return allowed
+}
+
+// verify checks the request's method and OAuth1 HMAC-SHA1 signature (signed
+// with the fixtures' consumer secret and tokenSecret) and returns its
+// parameters, it writes an error response when the request is invalid
+func (server *Server) verify(
+	w http.ResponseWriter,
+	r *http.Request,
+	method,
+	tokenSecret string,
+) (map[string]string, bool) {
+
+	if r.Method != method {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return nil, false
+	}
+
+	params := oauthParams(r)
+	if params["oauth_consumer_key"] != server.fixtures.ConsumerKey {
+		writeError(w, http.StatusUnauthorized, "invalid oauth_consumer_key")
+		return nil, false
+	}
+
+	if params["oauth_signature_method"] != "HMAC-SHA1" {
+		writeError(w, http.StatusUnauthorized, "unsupported signature method")
+		return nil, false
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	signed := make(map[string]string)
+	for key, values := range r.Form {
+		signed[key] = values[0]
+	}
+
+	for key, value := range params {
+		if key != "oauth_signature" && key != "realm" {
+			signed[key] = value
+		}
+	}
+
+	encoded := make(map[string]string)
+	keys := make([]string, 0, len(signed))
+
+	for key, value := range signed {
+		encodedKey := oauth1.PercentEncode(key)
+		encoded[encodedKey] = oauth1.PercentEncode(value)
+		keys = append(keys, encodedKey)
+	}
+
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+encoded[key])
+	}
+
+	baseURI := "http://" + strings.ToLower(r.Host) + r.URL.EscapedPath()
+	baseString := strings.Join([]string{
+		r.Method,
+		oauth1.PercentEncode(baseURI),
+		oauth1.PercentEncode(strings.Join(pairs, "&")),
+	}, "&")
+
+	key := oauth1.PercentEncode(server.fixtures.ConsumerSecret) +
+		"&" +
+		oauth1.PercentEncode(tokenSecret)
+
+	mac := hmac.New(sha1.New, []byte(key))
+	mac.Write([]byte(baseString))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(signature), []byte(params["oauth_signature"])) {
+		writeError(w, http.StatusUnauthorized, "invalid oauth_signature")
+		return nil, false
+	}
+
+	return params, true
+}
+
+// oauthParams parses the OAuth Authorization header parameters
+func oauthParams(r *http.Request) map[string]string {
+	params := make(map[string]string)
+	header := r.Header.Get("Authorization")
+
+	if !strings.HasPrefix(header, "OAuth ") {
+		return params
+	}
+
+	for _, pair := range strings.Split(strings.TrimPrefix(header, "OAuth "), ",") {
+		keyValue := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+
+		if len(keyValue) != 2 {
+			continue
+		}
+
+		value, err := url.PathUnescape(strings.Trim(keyValue[1], `"`))
+
+		if err != nil {
+			continue
+		}
+
+		params[keyValue[0]] = value
+	}
+
+	return params
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]interface{}{{"code": status, "message": message}},
+	})
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,275 @@
+package faketwitter_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Ahimta/tweeters-stats-golang/auth"
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/Ahimta/tweeters-stats-golang/faketwitter"
+	"github.com/Ahimta/tweeters-stats-golang/services"
+	"github.com/Ahimta/tweeters-stats-golang/sessions"
+	"github.com/Ahimta/tweeters-stats-golang/usecases"
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+var fixtures = faketwitter.Fixtures{
+	ConsumerKey:    "consumerKey",
+	ConsumerSecret: "consumerSecret",
+	AccessToken:    "accessToken",
+	AccessSecret:   "accessSecret",
+	ScreenName:     "jsmith",
+	Tweets: []twitter.Tweet{
+		{
+			ID:        1,
+			CreatedAt: "Sat Sep 01 10:00:00 +0000 2018",
+			User:      &twitter.User{Name: "John Smith", ScreenName: "jsmith"},
+		},
+		{
+			ID:        3,
+			CreatedAt: "Sat Sep 01 12:00:00 +0000 2018",
+			User:      &twitter.User{Name: "Jane Doe", ScreenName: "jdoe"},
+		},
+		{
+			ID:        2,
+			CreatedAt: "Sat Sep 01 11:00:00 +0000 2018",
+			User:      &twitter.User{Name: "Jane Doe", ScreenName: "jdoe"},
+		},
+	},
+}
+
+func newClient(
+	t *testing.T,
+	server *faketwitter.Server,
+	consumerSecret string,
+) auth.Oauth1Client {
+
+	client, err := auth.NewOauth1Client(
+		fixtures.ConsumerKey,
+		consumerSecret,
+		"http://localhost:8080/oauth/twitter/callback",
+		auth.TwitterEndpoint(server.URL),
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+func TestLoginToStats(t *testing.T) {
+	ctx := context.Background()
+
+	server := faketwitter.NewServer(fixtures)
+	defer server.Close()
+
+	client := newClient(t, server, fixtures.ConsumerSecret)
+	requestTokens := sessions.NewRequestTokenStore(usecases.LoginTTL)
+	login, err := usecases.Login(ctx, client, requestTokens)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noRedirects := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := noRedirects.Get(login.AuthorizationURL.String())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res.Body.Close()
+	callbackReq, err := http.NewRequest("GET", res.Header.Get("Location"), nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callback, err := usecases.Oauth1Callback(
+		ctx,
+		client,
+		requestTokens,
+		login.RequestToken,
+		callbackReq,
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if callback.AccessToken != fixtures.AccessToken ||
+		callback.AccessSecret != fixtures.AccessSecret {
+		t.Errorf("Incorrect access token: %v", callback)
+	}
+
+	//
+	_, err = usecases.Oauth1Callback(
+		ctx,
+		client,
+		requestTokens,
+		login.RequestToken,
+		callbackReq,
+	)
+
+	if err != usecases.ErrLoginReplayed {
+		t.Errorf("Should not exchange a request token twice")
+	}
+
+	//
+	tweetsService := services.NewTweetsService(client, 2, server.APIURL())
+	result, err := usecases.TweetersStats(
+		ctx,
+		tweetsService,
+		callback.AccessToken,
+		callback.AccessSecret,
+		usecases.TimeWindow{},
+		usecases.StatsQuery{},
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.TweetsCount != 2 ||
+		len(result.Stats) != 1 ||
+		result.Stats[0].Username != "jdoe" ||
+		result.Stats[0].TweetsCount != 2 {
+		t.Errorf("Incorrect stats: %v", result)
+	}
+
+	//
+	tweetsService = services.NewTweetsService(client, 800, server.APIURL())
+	result, err = usecases.TweetersStats(
+		ctx,
+		tweetsService,
+		callback.AccessToken,
+		callback.AccessSecret,
+		usecases.TimeWindow{},
+		usecases.StatsQuery{},
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.TweetsCount != 3 || result.PagesCount != 2 {
+		t.Errorf("Should page through the whole timeline: %v", result)
+	}
+}
+
+func TestInvalidSignature(t *testing.T) {
+	ctx := context.Background()
+
+	server := faketwitter.NewServer(fixtures)
+	defer server.Close()
+
+	//
+	_, err := usecases.Login(
+		ctx,
+		newClient(t, server, "wrongSecret"),
+		sessions.NewRequestTokenStore(usecases.LoginTTL),
+	)
+
+	if err == nil {
+		t.Errorf("Should reject a request signed with the wrong consumer secret")
+	}
+
+	//
+	tweetsService := services.NewTweetsService(
+		newClient(t, server, fixtures.ConsumerSecret),
+		800,
+		server.APIURL(),
+	)
+
+	_, err = usecases.TweetersStats(
+		ctx,
+		tweetsService,
+		fixtures.AccessToken,
+		"wrongSecret",
+		usecases.TimeWindow{},
+		usecases.StatsQuery{},
+	)
+
+	if err == nil || server.TimelineCalls() != 0 {
+		t.Errorf("Should reject a request signed with the wrong access secret")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	ctx := context.Background()
+
+	rateLimited := fixtures
+	rateLimited.TimelineRateLimit = 1
+
+	server := faketwitter.NewServer(rateLimited)
+	defer server.Close()
+
+	tweetsService := services.NewTweetsService(
+		newClient(t, server, fixtures.ConsumerSecret),
+		2,
+		server.APIURL(),
+	)
+
+	//
+	result, err := usecases.TweetersStats(
+		ctx,
+		tweetsService,
+		fixtures.AccessToken,
+		fixtures.AccessSecret,
+		usecases.TimeWindow{},
+		usecases.StatsQuery{},
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.RateLimit == nil ||
+		result.RateLimit.Limit != 1 ||
+		result.RateLimit.Remaining != 0 {
+		t.Errorf("Incorrect rate limit: %v", result.RateLimit)
+	}
+
+	//
+	_, err = usecases.TweetersStats(
+		ctx,
+		tweetsService,
+		fixtures.AccessToken,
+		fixtures.AccessSecret,
+		usecases.TimeWindow{},
+		usecases.StatsQuery{},
+	)
+
+	if _, ok := err.(*entities.RateLimitedError); !ok ||
+		server.TimelineCalls() != 1 {
+		t.Errorf("Should not call Twitter once the rate limit is exhausted")
+	}
+
+	//
+	tweetsService = services.NewTweetsService(
+		newClient(t, server, fixtures.ConsumerSecret),
+		2,
+		server.APIURL(),
+	)
+
+	_, err = usecases.TweetersStats(
+		ctx,
+		tweetsService,
+		fixtures.AccessToken,
+		fixtures.AccessSecret,
+		usecases.TimeWindow{},
+		usecases.StatsQuery{},
+	)
+
+	if _, ok := err.(*entities.RateLimitedError); !ok ||
+		server.TimelineCalls() != 2 {
+		t.Errorf("Should report Twitter's 429 as rate limited: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Ahimta/tweeters-stats-golang/collector"
+	"github.com/Ahimta/tweeters-stats-golang/config"
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/Ahimta/tweeters-stats-golang/sessions"
+)
+
+// CollectionResponse blablabla
+type CollectionResponse struct {
+	OptedIn bool                     `json:"optedIn"`
+	Data    *collector.AccountStatus `json:"data,omitempty"`
+}
+
+// CollectorResponse blablabla
+type CollectorResponse struct {
+	Data collector.Status `json:"data"`
+}
+
+// Collection lets the logged-in account see (GET), opt in to (PUT) and opt
+// out of (DELETE) background collection
+func Collection(
+	c *config.Config,
+	col *collector.Collector,
+	store sessions.Store) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		accessToken, accessSecret := sessionTokens(c, r, store)
+
+		if accessToken == "" || accessSecret == "" {
+			writeError(w, r, &entities.UnauthenticatedError{
+				Reason: "accessToken or accessSecret missing -_-",
+			})
+			return
+		}
+
+		var err error
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			err = col.OptIn(accessToken, accessSecret)
+		case http.MethodDelete:
+			err = col.OptOut(accessToken)
+		default:
+			writeMethodNotAllowed(w, r)
+			return
+		}
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		status, err := col.AccountStatus(accessToken)
+
+		if err == collector.ErrNotOptedIn {
+			json.NewEncoder(w).Encode(&CollectionResponse{})
+			return
+		}
+
+		json.NewEncoder(w).Encode(&CollectionResponse{OptedIn: true, Data: &status})
+	}
+}
+
+// AdminCollector shows the collector's status (GET) and starts or stops it
+// (POST with action=start or action=stop), requests need the admin token as
+// a bearer token
+func AdminCollector(c *config.Config, col *collector.Collector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if c.AdminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if !isAdmin(c, r) {
+			writeError(w, r, &entities.UnauthenticatedError{
+				Reason: "missing or invalid admin token",
+			})
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			switch r.URL.Query().Get("action") {
+			case "start":
+				col.Start()
+			case "stop":
+				col.Stop()
+			default:
+				writeError(w, r, &entities.InvalidInputError{
+					Message: "action must be start or stop",
+				})
+				return
+			}
+		default:
+			writeMethodNotAllowed(w, r)
+			return
+		}
+
+		json.NewEncoder(w).Encode(&CollectorResponse{Data: col.Status()})
+	}
+}
+
+func isAdmin(c *config.Config, r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(c.AdminToken)) == 1
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/collector"
+	"github.com/Ahimta/tweeters-stats-golang/config"
+	"github.com/Ahimta/tweeters-stats-golang/storage"
+)
+
+func newCollector(t *testing.T) (*collector.Collector, func()) {
+	dir, err := ioutil.TempDir("", "handlers")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := storage.NewBoltStore(
+		filepath.Join(dir, "history.db"),
+		[][]byte{c.CookieKey},
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the service is never called since the collector isn't started
+	col, err := collector.New(nil, store, store, time.Hour)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return col, func() {
+		store.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCollection(t *testing.T) {
+	col, cleanup := newCollector(t)
+	defer cleanup()
+
+	store, sessionCookie := newSession(t)
+	handler := Collection(c, col, store)
+
+	serve := func(method string, withSession bool) (int, CollectionResponse) {
+		req, err := http.NewRequest(method, "/collection", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if withSession {
+			req.AddCookie(sessionCookie)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		var responseBody CollectionResponse
+		json.NewDecoder(rr.Body).Decode(&responseBody)
+
+		return rr.Code, responseBody
+	}
+
+	//
+	if status, _ := serve("GET", false); status != http.StatusUnauthorized {
+		t.Errorf("Expected 401 HTTP status code without a session: %v", status)
+	}
+
+	//
+	if status, body := serve("GET", true); status != http.StatusOK || body.OptedIn {
+		t.Errorf("Should not be opted in by default: %v, %v", status, body)
+	}
+
+	//
+	status, body := serve("PUT", true)
+
+	if status != http.StatusOK || !body.OptedIn || body.Data == nil {
+		t.Errorf("Should opt in: %v, %v", status, body)
+	}
+
+	if status, body := serve("GET", true); status != http.StatusOK || !body.OptedIn {
+		t.Errorf("Should stay opted in: %v, %v", status, body)
+	}
+
+	//
+	if status, body := serve("DELETE", true); status != http.StatusOK || body.OptedIn {
+		t.Errorf("Should opt out: %v, %v", status, body)
+	}
+
+	//
+	if status, _ := serve("POST", true); status != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 HTTP status code: %v", status)
+	}
+}
+
+func TestLogout_collection(t *testing.T) {
+	col, cleanup := newCollector(t)
+	defer cleanup()
+
+	store, sessionCookie := newSession(t)
+
+	if err := col.OptIn("accessToken", "accessSecret"); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("DELETE", "/logout", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.AddCookie(sessionCookie)
+
+	rr := httptest.NewRecorder()
+	Logout(c, store, col).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected 204 HTTP status code: %v", rr.Code)
+	}
+
+	if _, err := col.AccountStatus("accessToken"); err != collector.ErrNotOptedIn {
+		t.Errorf("Should opt the account out on logout: %v", err)
+	}
+}
+
+func TestAdminCollector(t *testing.T) {
+	col, cleanup := newCollector(t)
+	defer cleanup()
+
+	adminConfig := *c
+	adminConfig.AdminToken = "adminToken"
+
+	serve := func(
+		c *config.Config,
+		method,
+		target,
+		token string,
+	) (int, CollectorResponse) {
+
+		req, err := http.NewRequest(method, target, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rr := httptest.NewRecorder()
+		AdminCollector(c, col).ServeHTTP(rr, req)
+
+		var responseBody CollectorResponse
+		json.NewDecoder(rr.Body).Decode(&responseBody)
+
+		return rr.Code, responseBody
+	}
+
+	//
+	if status, _ := serve(c, "GET", "/admin/collector", ""); status != http.StatusNotFound {
+		t.Errorf("Should be disabled without an admin token: %v", status)
+	}
+
+	//
+	if status, _ := serve(&adminConfig, "GET", "/admin/collector", "blablabla"); status != http.StatusUnauthorized {
+		t.Errorf("Expected 401 HTTP status code for invalid tokens: %v", status)
+	}
+
+	//
+	status, body := serve(&adminConfig, "GET", "/admin/collector", "adminToken")
+
+	if status != http.StatusOK || body.Data.Running {
+		t.Errorf("Should return the collector's status: %v, %v", status, body)
+	}
+
+	//
+	status, body = serve(&adminConfig, "POST", "/admin/collector?action=start", "adminToken")
+
+	if status != http.StatusOK || !body.Data.Running {
+		t.Errorf("Should start the collector: %v, %v", status, body)
+	}
+
+	//
+	status, body = serve(&adminConfig, "POST", "/admin/collector?action=stop", "adminToken")
+
+	if status != http.StatusOK || body.Data.Running {
+		t.Errorf("Should stop the collector: %v, %v", status, body)
+	}
+
+	//
+	if status, _ := serve(&adminConfig, "POST", "/admin/collector?action=restart", "adminToken"); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 HTTP status code for invalid actions: %v", status)
+	}
+}
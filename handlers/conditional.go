@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// writeConditionalJSON writes value as JSON along with ETag and Last-Modified
+// headers, or just a 304 when the request's validators still match
+func writeConditionalJSON(
+	w http.ResponseWriter,
+	r *http.Request,
+	value interface{},
+	lastModified time.Time,
+) {
+
+	data, err := json.Marshal(value)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// same as json.Encoder's output
+	data = append(data, '\n')
+
+	writeConditional(w, r, "application/json", data, lastModified)
+}
+
+// writeConditional is writeConditionalJSON for data that's already encoded
+func writeConditional(
+	w http.ResponseWriter,
+	r *http.Request,
+	contentType string,
+	data []byte,
+	lastModified time.Time,
+) {
+
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	lastModified = lastModified.UTC().Truncate(time.Second)
+
+	header := w.Header()
+	header.Set("ETag", etag)
+	header.Set("Last-Modified", lastModified.Format(http.TimeFormat))
+
+	// stats are per account, and browsers should always revalidate them
+	header.Set("Cache-Control", "private, no-cache")
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	header.Set("Content-Type", contentType)
+	w.Write(data)
+}
+
+// notModified follows RFC 7232, If-None-Match takes precedence over
+// If-Modified-Since
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !lastModified.After(ifModifiedSince)
+}
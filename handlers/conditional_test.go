@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_notModified(t *testing.T) {
+	lastModified := time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   bool
+	}{
+		{
+			name:   "should be modified without validators",
+			header: http.Header{},
+			want:   false,
+		},
+		{
+			name:   "should match one of several ETags",
+			header: http.Header{"If-None-Match": {`"a", W/"etag"`}},
+			want:   true,
+		},
+		{
+			name:   "should match any ETag",
+			header: http.Header{"If-None-Match": {"*"}},
+			want:   true,
+		},
+		{
+			name: "should ignore If-Modified-Since when If-None-Match is present",
+			header: http.Header{
+				"If-None-Match":     {`"a"`},
+				"If-Modified-Since": {"Sat, 01 Sep 2018 12:00:00 GMT"},
+			},
+			want: false,
+		},
+		{
+			name:   "should ignore an invalid If-Modified-Since",
+			header: http.Header{"If-Modified-Since": {"yesterday"}},
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &http.Request{Header: tt.header}
+
+			if got := notModified(r, `"etag"`, lastModified); got != tt.want {
+				t.Errorf("notModified() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/config"
+	"github.com/Ahimta/tweeters-stats-golang/seal"
+	"github.com/Ahimta/tweeters-stats-golang/sessions"
+)
+
+const (
+	requestTokenCookie = "oauthRequestToken"
+	sessionCookie      = "session"
+
+	// secrets used to be stored in plain cookies, they're only cleared now
+	legacyRequestSecretCookie = "oauthRequestSecret"
+	legacyAccessTokenCookie   = "accessToken"
+	legacyAccessSecretCookie  = "accessSecret"
+
+	// how long sealed values of browser-session cookies (i.e., a zero maxAge)
+	// are accepted, browsers may keep those around for much longer
+	browserSessionCookieTTL = time.Hour
+)
+
+// newCookie returns an HttpOnly cookie (Secure when served over HTTPS) with
+// its value sealed by sealCookieValue, a zero maxAge makes it a session cookie
+func newCookie(
+	c *config.Config,
+	name,
+	value string,
+	maxAge int,
+) (*http.Cookie, error) {
+
+	ttl := time.Duration(maxAge) * time.Second
+	if maxAge == 0 {
+		ttl = browserSessionCookieTTL
+	}
+
+	sealed, err := sealCookieValue(c.CookieKey, name, value, time.Now().Add(ttl))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return plainCookie(c, name, sealed, maxAge), nil
+}
+
+func plainCookie(
+	c *config.Config,
+	name,
+	value string,
+	maxAge int,
+) *http.Cookie {
+
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   c.Protocol == "https",
+
+		// Lax (rather than Strict) so cookies survive the redirect back from
+		// Twitter's authorization page
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func expiredCookie(c *config.Config, name string) *http.Cookie {
+	return plainCookie(c, name, "", -1)
+}
+
+// cookieValue returns an empty value for missing, tampered or expired cookies
+func cookieValue(c *config.Config, r *http.Request, name string) string {
+	cookie, err := r.Cookie(name)
+
+	if err != nil {
+		return ""
+	}
+
+	keys := append([][]byte{c.CookieKey}, c.CookieOldKeys...)
+	value, err := openCookieValue(keys, name, cookie.Value, time.Now())
+
+	if err != nil {
+		return ""
+	}
+
+	return value
+}
+
+// sessionTokens returns empty tokens when there's no valid session, leaving it
+// to usecases to reject the request
+func sessionTokens(
+	c *config.Config,
+	r *http.Request,
+	store sessions.Store,
+) (accessToken, accessSecret string) {
+
+	id := cookieValue(c, r, sessionCookie)
+
+	if id == "" {
+		return "", ""
+	}
+
+	session, err := store.Get(id)
+
+	if err != nil {
+		return "", ""
+	}
+
+	return session.AccessToken, session.AccessSecret
+}
+
+// sealCookieValue encrypts value and its expiry, the cookie name is
+// authenticated too so a sealed value can't be replayed in another cookie
+func sealCookieValue(
+	key []byte,
+	name,
+	value string,
+	expiresAt time.Time,
+) (string, error) {
+
+	plaintext := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(plaintext, uint64(expiresAt.Unix()))
+	plaintext = append(plaintext, value...)
+
+	sealed, err := seal.Seal(key, plaintext, []byte(name))
+
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// openCookieValue tries every key in turn, so cookies sealed before a key
+// rotation stay valid until they expire
+func openCookieValue(
+	keys [][]byte,
+	name,
+	sealed string,
+	now time.Time,
+) (string, error) {
+
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, _, err := seal.Open(keys, data, []byte(name))
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(plaintext) < 8 {
+		return "", errors.New("handlers: malformed cookie")
+	}
+
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(plaintext)), 0)
+
+	if !now.Before(expiresAt) {
+		return "", errors.New("handlers: expired cookie")
+	}
+
+	return string(plaintext[8:]), nil
+}
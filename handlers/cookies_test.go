@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_openCookieValue(t *testing.T) {
+	key := []byte("kkkkkkkkkkkkkkkkkkkkkkkkkkkkkkkk")
+	oldKey := []byte("oooooooooooooooo")
+	now := time.Now()
+
+	seal := func(key []byte, name string, expiresAt time.Time) string {
+		sealed, err := sealCookieValue(key, name, "blablabla", expiresAt)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return sealed
+	}
+
+	sealed := seal(key, "session", now.Add(time.Hour))
+	tampered := []byte(sealed)
+	if tampered[20] == 'A' {
+		tampered[20] = 'B'
+	} else {
+		tampered[20] = 'A'
+	}
+
+	tests := []struct {
+		name    string
+		keys    [][]byte
+		cookie  string
+		sealed  string
+		wantErr bool
+	}{
+		{
+			name:   "should open a value sealed with the current key",
+			keys:   [][]byte{key, oldKey},
+			cookie: "session",
+			sealed: sealed,
+		},
+		{
+			name:   "should open a value sealed with an old key",
+			keys:   [][]byte{key, oldKey},
+			cookie: "session",
+			sealed: seal(oldKey, "session", now.Add(time.Hour)),
+		},
+		{
+			name:    "should reject a value sealed with an unknown key",
+			keys:    [][]byte{key},
+			cookie:  "session",
+			sealed:  seal(oldKey, "session", now.Add(time.Hour)),
+			wantErr: true,
+		},
+		{
+			name:    "should reject a tampered value",
+			keys:    [][]byte{key},
+			cookie:  "session",
+			sealed:  string(tampered),
+			wantErr: true,
+		},
+		{
+			name:    "should reject a value sealed for another cookie",
+			keys:    [][]byte{key},
+			cookie:  "oauthRequestSecret",
+			sealed:  sealed,
+			wantErr: true,
+		},
+		{
+			name:    "should reject an expired value",
+			keys:    [][]byte{key},
+			cookie:  "session",
+			sealed:  seal(key, "session", now.Add(-time.Second)),
+			wantErr: true,
+		},
+		{
+			name:    "should reject a plain value",
+			keys:    [][]byte{key},
+			cookie:  "session",
+			sealed:  "blablabla",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := openCookieValue(tt.keys, tt.cookie, tt.sealed, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("openCookieValue() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got != "blablabla" {
+				t.Errorf("openCookieValue() = %v, want %v", got, "blablabla")
+			}
+		})
+	}
+}
+
+func Test_newCookie(t *testing.T) {
+	cookie, err := newCookie(c, "session", "blablabla", 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cookie.MaxAge != 0 ||
+		!cookie.HttpOnly ||
+		strings.Contains(cookie.Value, "blablabla") {
+		t.Errorf("Whaaat! %v", cookie)
+	}
+
+	r := &http.Request{Header: http.Header{}}
+	r.AddCookie(cookie)
+
+	if value := cookieValue(c, r, "session"); value != "blablabla" {
+		t.Errorf("Should open its own cookies: %v", value)
+	}
+
+	r = &http.Request{Header: http.Header{}}
+	r.AddCookie(&http.Cookie{Name: "session", Value: "blablabla"})
+
+	if value := cookieValue(c, r, "session"); value != "" {
+		t.Errorf("Should ignore unsealed cookies: %v", value)
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+)
+
+// Heroku's router (and most proxies) already set this header
+const requestIDHeader = "X-Request-ID"
+
+// client-supplied IDs end up in logs and responses, so anything else (e.g.,
+// newlines or huge values) is replaced with a new ID
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9-]{1,64}$`)
+
+// nginx's non-standard status for clients closing the connection before the
+// response, it keeps cancellations out of the 5xx error metrics
+const statusClientClosedRequest = 499
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody blablabla
+type ErrorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"requestId"`
+
+	// only set for rate_limited errors when Twitter says when it resets
+	ResetAt *time.Time `json:"resetAt,omitempty"`
+}
+
+// writeError logs err and maps it to a status code and an ErrorResponse,
+// errors other than the ones in entities are reported as internal errors
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status, body := errorBody(w, r, err)
+	writeErrorBody(w, status, body)
+}
+
+// errorBody is writeError without writing anything but headers (e.g., for
+// errors in the middle of a stream)
+func errorBody(w http.ResponseWriter, r *http.Request, err error) (
+	int, ErrorBody,
+) {
+
+	status := http.StatusInternalServerError
+	body := ErrorBody{Code: "internal_error", Message: "internal error"}
+
+	switch err := err.(type) {
+	case *entities.UnauthenticatedError:
+		status = http.StatusUnauthorized
+		body = ErrorBody{Code: "unauthenticated", Message: err.Error()}
+	case *entities.RateLimitedError:
+		status = http.StatusTooManyRequests
+		body = ErrorBody{Code: "rate_limited", Message: err.Error()}
+
+		if !err.Reset.IsZero() {
+			body.ResetAt = &err.Reset
+			w.Header().Set("Retry-After", retryAfter(err.Reset, time.Now()))
+		}
+	case *entities.UpstreamError:
+		status = http.StatusBadGateway
+		body = ErrorBody{
+			Code:    "upstream_unavailable",
+			Message: "Twitter is unavailable, try again later",
+		}
+	case *entities.TimeoutError:
+		status = http.StatusGatewayTimeout
+		body = ErrorBody{
+			Code:    "timeout",
+			Message: "Twitter took too long, try again later",
+		}
+	case *entities.CanceledError:
+		status = statusClientClosedRequest
+		body = ErrorBody{Code: "canceled", Message: err.Error()}
+	case *entities.InvalidInputError:
+		status = http.StatusBadRequest
+		body = ErrorBody{Code: "invalid_input", Message: err.Error()}
+	}
+
+	body.RequestID = requestID(w, r)
+	log.Println(body.RequestID, err)
+
+	return status, body
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeErrorBody(w, http.StatusMethodNotAllowed, ErrorBody{
+		Code:      "method_not_allowed",
+		Message:   r.Method + " is not allowed",
+		RequestID: requestID(w, r),
+	})
+}
+
+func writeErrorBody(w http.ResponseWriter, status int, body ErrorBody) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&ErrorResponse{Error: body})
+}
+
+// retryAfter is the Retry-After value (in whole seconds, rounded up) for a
+// rate limit that resets at reset
+func retryAfter(reset, now time.Time) string {
+	seconds := int64((reset.Sub(now) + time.Second - 1) / time.Second)
+
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return strconv.FormatInt(seconds, 10)
+}
+
+// requestID reuses the request's ID (when set by a proxy and valid) or makes
+// up a new one, and echoes it back so users can quote it in bug reports
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	id := r.Header.Get(requestIDHeader)
+
+	if !requestIDPattern.MatchString(id) {
+		b := make([]byte, 16)
+
+		if _, err := rand.Read(b); err == nil {
+			id = hex.EncodeToString(b)
+		}
+	}
+
+	w.Header().Set(requestIDHeader, id)
+	return id
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+)
+
+func Test_writeError(t *testing.T) {
+	reset := time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantReset  bool
+	}{
+		{
+			name:       "should map unauthenticated errors to 401",
+			err:        &entities.UnauthenticatedError{Reason: "blablabla"},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "unauthenticated",
+		},
+		{
+			name:       "should map rate-limited errors to 429 with the reset time",
+			err:        &entities.RateLimitedError{Reset: reset},
+			wantStatus: http.StatusTooManyRequests,
+			wantCode:   "rate_limited",
+			wantReset:  true,
+		},
+		{
+			name:       "should map upstream errors to 502",
+			err:        &entities.UpstreamError{Err: errors.New("blablabla")},
+			wantStatus: http.StatusBadGateway,
+			wantCode:   "upstream_unavailable",
+		},
+		{
+			name:       "should map timeouts to 504",
+			err:        &entities.TimeoutError{},
+			wantStatus: http.StatusGatewayTimeout,
+			wantCode:   "timeout",
+		},
+		{
+			name:       "should map cancellations to 499",
+			err:        &entities.CanceledError{},
+			wantStatus: 499,
+			wantCode:   "canceled",
+		},
+		{
+			name:       "should map invalid input errors to 400",
+			err:        &entities.InvalidInputError{Message: "blablabla"},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_input",
+		},
+		{
+			name:       "should map other errors to 500",
+			err:        errors.New("blablabla"),
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   "internal_error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/tweeters-stats", nil)
+			req.Header.Set("X-Request-ID", "requestID")
+
+			rr := httptest.NewRecorder()
+			writeError(rr, req, tt.err)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("writeError() status = %v, want %v", rr.Code, tt.wantStatus)
+			}
+
+			if contentType := rr.Header().Get("Content-Type"); contentType !=
+				"application/json" {
+				t.Errorf("Incorrect Content-Type value: %v", contentType)
+			}
+
+			if retryAfter := rr.Header().Get("Retry-After"); (retryAfter != "") !=
+				tt.wantReset {
+				t.Errorf("Incorrect Retry-After value: %v", retryAfter)
+			}
+
+			if id := rr.Header().Get("X-Request-ID"); id != "requestID" {
+				t.Errorf("Should echo the request ID: %v", id)
+			}
+
+			var body ErrorResponse
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+
+			if body.Error.Code != tt.wantCode ||
+				body.Error.Message == "" ||
+				body.Error.RequestID != "requestID" ||
+				(body.Error.ResetAt != nil) != tt.wantReset ||
+				(tt.wantReset && !body.Error.ResetAt.Equal(reset)) {
+				t.Errorf("Incorrect response body: %v", body)
+			}
+		})
+	}
+}
+
+func Test_retryAfter(t *testing.T) {
+	now := time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		reset time.Time
+		want  string
+	}{
+		{"should round up to whole seconds", now.Add(1500 * time.Millisecond), "2"},
+		{"should count whole seconds", now.Add(15 * time.Minute), "900"},
+		{"should wait at least a second", now.Add(-time.Minute), "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryAfter(tt.reset, now); got != tt.want {
+				t.Errorf("retryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_requestID(t *testing.T) {
+	rr := httptest.NewRecorder()
+	id := requestID(rr, httptest.NewRequest("GET", "/", nil))
+
+	if len(id) != 32 || rr.Header().Get("X-Request-ID") != id {
+		t.Errorf("Should make up a request ID when there's none: %v", id)
+	}
+
+	//
+
+	for _, invalid := range []string{
+		"request\nID",
+		"request ID",
+		strings.Repeat("a", 65),
+	} {
+		rr = httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-ID", invalid)
+		id = requestID(rr, req)
+
+		if id == invalid || len(id) != 32 || rr.Header().Get("X-Request-ID") != id {
+			t.Errorf("Should replace an invalid request ID (%q): %v", invalid, id)
+		}
+	}
+
+	//
+
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "2b3c1a9e-6f1d-4c1b-9b6a-0d2f1e3c4b5a")
+
+	if id := requestID(rr, req); id != "2b3c1a9e-6f1d-4c1b-9b6a-0d2f1e3c4b5a" {
+		t.Errorf("Should reuse a valid request ID: %v", id)
+	}
+}
@@ -0,0 +1,418 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+)
+
+// statsTable is a stats ranking that can be exported one row per item
+type statsTable interface {
+	len() int
+	header() []string
+	row(i int) []string
+	item(i int) interface{}
+}
+
+type exportFormat struct {
+	contentType string
+	extension   string
+	write       func(w io.Writer, table statsTable) error
+}
+
+// exportFormats are the alternatives to the usual JSON response, by format
+// query parameter
+var exportFormats = map[string]*exportFormat{
+	"csv":    {"text/csv; charset=utf-8", "csv", writeSeparated(',')},
+	"tsv":    {"text/tab-separated-values; charset=utf-8", "tsv", writeSeparated('\t')},
+	"ndjson": {"application/x-ndjson", "ndjson", writeNDJSON},
+}
+
+// negotiateExport picks one of formats by the format query parameter or else
+// by the Accept type with the highest q, a nil format means JSON (which is
+// also the fallback for unsupported types)
+func negotiateExport(
+	w http.ResponseWriter,
+	r *http.Request,
+	formats map[string]*exportFormat,
+) (
+	*exportFormat, error,
+) {
+
+	w.Header().Add("Vary", "Accept")
+
+	if name := r.URL.Query().Get("format"); name != "" {
+		format, ok := formats[name]
+
+		if !ok && name != "json" {
+			return nil, &entities.InvalidInputError{
+				Message: "format must be " + formatNames(formats),
+			}
+		}
+
+		return format, nil
+	}
+
+	var best *exportFormat
+	bestQ := 0.0
+
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(accepted)
+
+		if err != nil {
+			continue
+		}
+
+		format, ok := formatByMediaType(formats, mediaType)
+
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+
+	return best, nil
+}
+
+// formatByMediaType is the format served as mediaType, nil for JSON
+func formatByMediaType(formats map[string]*exportFormat, mediaType string) (
+	*exportFormat, bool,
+) {
+
+	if mediaType == "application/json" {
+		return nil, true
+	}
+
+	for _, format := range formats {
+		contentType, _, _ := mime.ParseMediaType(format.contentType)
+
+		if contentType == mediaType {
+			return format, true
+		}
+	}
+
+	return nil, false
+}
+
+// formatNames lists json and formats' names (e.g., "json, csv or tsv")
+func formatNames(formats map[string]*exportFormat) string {
+	names := make([]string, 0, len(formats))
+
+	for name := range formats {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	names = append([]string{"json"}, names...)
+
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+// encodeExport also sets the headers of the export, its file is named after
+// the endpoint (e.g., hashtags.csv)
+func encodeExport(
+	w http.ResponseWriter,
+	r *http.Request,
+	format *exportFormat,
+	table statsTable,
+) (
+	[]byte, error,
+) {
+
+	var b bytes.Buffer
+
+	if err := format.write(&b, table); err != nil {
+		return nil, err
+	}
+
+	w.Header().Set(
+		"Content-Disposition",
+		mime.FormatMediaType("attachment", map[string]string{
+			"filename": path.Base(r.URL.Path) + "." + format.extension,
+		}),
+	)
+
+	return b.Bytes(), nil
+}
+
+// writeExport writes table in format, or value as JSON when format is nil
+func writeExport(
+	w http.ResponseWriter,
+	r *http.Request,
+	format *exportFormat,
+	table statsTable,
+	value interface{},
+) {
+
+	if format == nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(value)
+		return
+	}
+
+	data, err := encodeExport(w, r, format, table)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.contentType)
+	w.Write(data)
+}
+
+func writeSeparated(comma rune) func(io.Writer, statsTable) error {
+	return func(w io.Writer, table statsTable) error {
+		writer := csv.NewWriter(w)
+		writer.Comma = comma
+
+		if err := writer.Write(table.header()); err != nil {
+			return err
+		}
+
+		for i := 0; i < table.len(); i++ {
+			row := table.row(i)
+
+			for j, cell := range row {
+				row[j] = spreadsheetSafe(cell)
+			}
+
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+
+		writer.Flush()
+		return writer.Error()
+	}
+}
+
+func writeNDJSON(w io.Writer, table statsTable) error {
+	encoder := json.NewEncoder(w)
+
+	for i := 0; i < table.len(); i++ {
+		if err := encoder.Encode(table.item(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// spreadsheetSafe keeps spreadsheets from evaluating cells (e.g., a full name
+// like =HYPERLINK(...)) as formulas
+func spreadsheetSafe(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+
+	return cell
+}
+
+func formatUint(n uint) string {
+	return strconv.FormatUint(uint64(n), 10)
+}
+
+func formatPercent(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+type tweeterStatsTable []*entities.TweeterStats
+
+func (table tweeterStatsTable) len() int { return len(table) }
+
+func (table tweeterStatsTable) header() []string {
+	return []string{
+		"username",
+		"fullName",
+		"tweetsCount",
+		"originalCount",
+		"retweetCount",
+		"replyCount",
+		"quoteCount",
+		"percent",
+		"cumulativePercent",
+	}
+}
+
+func (table tweeterStatsTable) row(i int) []string {
+	stats := table[i]
+
+	return []string{
+		stats.Username,
+		stats.FullName,
+		formatUint(stats.TweetsCount),
+		formatUint(stats.OriginalCount),
+		formatUint(stats.RetweetCount),
+		formatUint(stats.ReplyCount),
+		formatUint(stats.QuoteCount),
+		formatPercent(stats.Percent),
+		formatPercent(stats.CumulativePercent),
+	}
+}
+
+func (table tweeterStatsTable) item(i int) interface{} { return table[i] }
+
+type amplifiedStatsTable []*entities.AmplifiedStats
+
+func (table amplifiedStatsTable) len() int { return len(table) }
+
+func (table amplifiedStatsTable) header() []string {
+	return []string{"username", "fullName", "retweetsCount", "retweetersCount"}
+}
+
+func (table amplifiedStatsTable) row(i int) []string {
+	stats := table[i]
+
+	return []string{
+		stats.Username,
+		stats.FullName,
+		formatUint(stats.RetweetsCount),
+		formatUint(stats.RetweetersCount),
+	}
+}
+
+func (table amplifiedStatsTable) item(i int) interface{} { return table[i] }
+
+type entityStatsTable []*entities.EntityStats
+
+func (table entityStatsTable) len() int { return len(table) }
+
+func (table entityStatsTable) header() []string {
+	return []string{"name", "fullName", "tweetsCount", "tweetersCount"}
+}
+
+func (table entityStatsTable) row(i int) []string {
+	stats := table[i]
+
+	return []string{
+		stats.Name,
+		stats.FullName,
+		formatUint(stats.TweetsCount),
+		formatUint(stats.TweetersCount),
+	}
+}
+
+func (table entityStatsTable) item(i int) interface{} { return table[i] }
+
+// time series are exported one row per point
+type timeSeriesRow struct {
+	Username string    `json:"username"`
+	FullName string    `json:"fullName"`
+	Time     time.Time `json:"time"`
+	Count    uint      `json:"count"`
+}
+
+type timeSeriesTable []*timeSeriesRow
+
+func newTimeSeriesTable(series []*entities.TweeterTimeSeries) timeSeriesTable {
+	table := timeSeriesTable{}
+
+	for _, tweeterSeries := range series {
+		for _, point := range tweeterSeries.Points {
+			table = append(table, &timeSeriesRow{
+				Username: tweeterSeries.Username,
+				FullName: tweeterSeries.FullName,
+				Time:     point.Time,
+				Count:    point.Count,
+			})
+		}
+	}
+
+	return table
+}
+
+func (table timeSeriesTable) len() int { return len(table) }
+
+func (table timeSeriesTable) header() []string {
+	return []string{"username", "fullName", "time", "count"}
+}
+
+func (table timeSeriesTable) row(i int) []string {
+	row := table[i]
+
+	return []string{
+		row.Username,
+		row.FullName,
+		row.Time.Format(time.RFC3339),
+		formatUint(row.Count),
+	}
+}
+
+func (table timeSeriesTable) item(i int) interface{} { return table[i] }
+
+// heatmaps are exported one row per hour with tweets, weekdays and hours being
+// in the requested timezone
+type heatmapRow struct {
+	Username string `json:"username"`
+	FullName string `json:"fullName"`
+	Weekday  string `json:"weekday"`
+	Hour     int    `json:"hour"`
+	Count    uint   `json:"count"`
+}
+
+type heatmapTable []*heatmapRow
+
+func newHeatmapTable(heatmaps []*entities.TweeterHeatmap) heatmapTable {
+	table := heatmapTable{}
+
+	for _, tweeterHeatmap := range heatmaps {
+		for weekday, hours := range tweeterHeatmap.Heatmap {
+			for hour, count := range hours {
+				if count == 0 {
+					continue
+				}
+
+				table = append(table, &heatmapRow{
+					Username: tweeterHeatmap.Username,
+					FullName: tweeterHeatmap.FullName,
+					Weekday:  time.Weekday(weekday).String(),
+					Hour:     hour,
+					Count:    count,
+				})
+			}
+		}
+	}
+
+	return table
+}
+
+func (table heatmapTable) len() int { return len(table) }
+
+func (table heatmapTable) header() []string {
+	return []string{"username", "fullName", "weekday", "hour", "count"}
+}
+
+func (table heatmapTable) row(i int) []string {
+	row := table[i]
+
+	return []string{
+		row.Username,
+		row.FullName,
+		row.Weekday,
+		strconv.Itoa(row.Hour),
+		formatUint(row.Count),
+	}
+}
+
+func (table heatmapTable) item(i int) interface{} { return table[i] }
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/Ahimta/tweeters-stats-golang/services"
+	"github.com/Ahimta/tweeters-stats-golang/usecases"
+)
+
+func TestNegotiateExport(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		accept  string
+		want    *exportFormat
+		wantErr bool
+	}{
+		{"should default to JSON", "/", "", nil, false},
+		{"should fall back to JSON", "/", "text/html,*/*;q=0.8", nil, false},
+		{"should honor Accept", "/", "text/csv", exportFormats["csv"], false},
+		{"should honor q", "/", "text/csv;q=0.5, application/x-ndjson", exportFormats["ndjson"], false},
+		{"should skip q=0", "/", "text/tab-separated-values;q=0", nil, false},
+		{"should prefer format", "/?format=tsv", "text/csv", exportFormats["tsv"], false},
+		{"should accept format=json", "/?format=json", "text/csv", nil, false},
+		{"should reject unknown formats", "/?format=xlsx", "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			req.Header.Set("Accept", tt.accept)
+
+			rr := httptest.NewRecorder()
+			got, err := negotiateExport(rr, req, exportFormats)
+
+			if _, ok := err.(*entities.InvalidInputError); ok != tt.wantErr {
+				t.Errorf("negotiateExport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("negotiateExport() = %v, want %v", got, tt.want)
+			}
+
+			if rr.Header().Get("Vary") != "Accept" {
+				t.Errorf("Should vary by Accept")
+			}
+		})
+	}
+}
+
+func TestTweetersStats_export(t *testing.T) {
+	store, sessionCookie := newSession(t)
+
+	usecase := func(
+		ctx context.Context,
+		service services.TweetsService, accessToken,
+		accessSecret string,
+		window usecases.TimeWindow,
+		query usecases.StatsQuery,
+	) (
+		*usecases.TweetersStatsResult, error,
+	) {
+
+		return &usecases.TweetersStatsResult{
+			Stats: []*entities.TweeterStats{
+				{FullName: "Smith, John", Username: "jsmith", TweetsCount: 3, Percent: 75},
+				{FullName: "=1+1", Username: "evil", TweetsCount: 1, Percent: 25},
+			},
+		}, nil
+	}
+
+	serve := func(target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Accept", accept)
+		req.AddCookie(sessionCookie)
+
+		rr := httptest.NewRecorder()
+		TweetersStats(usecase, c, nil, store).ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	//
+	rr := serve("/tweeters-stats", "text/csv")
+	lines := strings.Split(rr.Body.String(), "\n")
+
+	if rr.Code != http.StatusOK ||
+		rr.Header().Get("Content-Type") != "text/csv; charset=utf-8" ||
+		rr.Header().Get("Content-Disposition") != `attachment; filename=tweeters-stats.csv` {
+
+		t.Errorf("Incorrect CSV headers: %v, %v", rr.Code, rr.Header())
+	}
+
+	if len(lines) != 4 ||
+		!strings.HasPrefix(lines[0], "username,fullName,tweetsCount,") ||
+		lines[1] != `jsmith,"Smith, John",3,0,0,0,0,75,0` ||
+		lines[2] != `evil,'=1+1,1,0,0,0,0,25,0` {
+
+		t.Errorf("Incorrect CSV body: %q", lines)
+	}
+
+	// exports are conditional too
+	req := httptest.NewRequest("GET", "/tweeters-stats?format=csv", nil)
+	req.Header.Set("If-None-Match", rr.Header().Get("ETag"))
+	req.AddCookie(sessionCookie)
+
+	rr304 := httptest.NewRecorder()
+	TweetersStats(usecase, c, nil, store).ServeHTTP(rr304, req)
+
+	if rr304.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 HTTP status code: %v", rr304.Code)
+	}
+
+	//
+	rr = serve("/tweeters-stats?format=tsv", "")
+
+	if !strings.Contains(rr.Body.String(), "jsmith\tSmith, John\t3\t") {
+		t.Errorf("Incorrect TSV body: %q", rr.Body.String())
+	}
+
+	//
+	rr = serve("/tweeters-stats", "application/x-ndjson")
+	decoder := json.NewDecoder(rr.Body)
+	usernames := []string{}
+
+	for decoder.More() {
+		var stats entities.TweeterStats
+
+		if err := decoder.Decode(&stats); err != nil {
+			t.Fatal(err)
+		}
+
+		usernames = append(usernames, stats.Username)
+	}
+
+	if rr.Header().Get("Content-Type") != "application/x-ndjson" ||
+		strings.Join(usernames, ",") != "jsmith,evil" {
+
+		t.Errorf("Incorrect NDJSON response: %q", rr.Body.String())
+	}
+
+	//
+	if rr := serve("/tweeters-stats?format=xlsx", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 HTTP status code: %v", rr.Code)
+	}
+}
+
+func TestTimeSeries_export(t *testing.T) {
+	store, sessionCookie := newSession(t)
+
+	usecase := func(
+		ctx context.Context,
+		service services.TweetsService,
+		accessToken,
+		accessSecret string,
+		window usecases.TimeWindow,
+		query usecases.TimeSeriesQuery,
+	) (
+		*usecases.TimeSeriesResult, error,
+	) {
+
+		return &usecases.TimeSeriesResult{
+			Series: []*entities.TweeterTimeSeries{{
+				FullName: "Jane Doe",
+				Username: "jdoe",
+				Points: []entities.TimeSeriesPoint{
+					{Time: time.Date(2018, 9, 1, 0, 0, 0, 0, time.UTC), Count: 2},
+					{Time: time.Date(2018, 9, 2, 0, 0, 0, 0, time.UTC), Count: 1},
+				},
+			}},
+		}, nil
+	}
+
+	req := httptest.NewRequest("GET", "/tweeters-stats/timeseries", nil)
+	req.AddCookie(sessionCookie)
+
+	rr := httptest.NewRecorder()
+	TimeSeries(usecase, c, nil, store).ServeHTTP(rr, req)
+
+	if contentType := rr.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Incorrect JSON Content-Type: %v", contentType)
+	}
+
+	//
+	req = httptest.NewRequest("GET", "/tweeters-stats/timeseries?format=csv", nil)
+	req.AddCookie(sessionCookie)
+
+	rr = httptest.NewRecorder()
+	TimeSeries(usecase, c, nil, store).ServeHTTP(rr, req)
+
+	if want := "username,fullName,time,count\n" +
+		"jdoe,Jane Doe,2018-09-01T00:00:00Z,2\n" +
+		"jdoe,Jane Doe,2018-09-02T00:00:00Z,1\n"; rr.Body.String() != want ||
+		rr.Header().Get("Content-Disposition") != `attachment; filename=timeseries.csv` {
+
+		t.Errorf("Incorrect CSV export: %v, %q", rr.Header(), rr.Body.String())
+	}
+}
+
+func TestHeatmap_export(t *testing.T) {
+	store, sessionCookie := newSession(t)
+
+	heatmap := entities.Heatmap{}
+	heatmap[time.Monday][9] = 3
+
+	usecase := func(
+		ctx context.Context,
+		service services.TweetsService,
+		accessToken,
+		accessSecret string,
+		window usecases.TimeWindow,
+		location *time.Location,
+	) (
+		*usecases.HeatmapResult, error,
+	) {
+
+		return &usecases.HeatmapResult{
+			Heatmaps: []*entities.TweeterHeatmap{
+				{FullName: "Jane Doe", Username: "jdoe", TweetsCount: 3, Heatmap: heatmap},
+			},
+		}, nil
+	}
+
+	req := httptest.NewRequest("GET", "/tweeters-stats/heatmap", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	req.AddCookie(sessionCookie)
+
+	rr := httptest.NewRecorder()
+	Heatmap(usecase, c, nil, store).ServeHTTP(rr, req)
+
+	if want := `{"username":"jdoe","fullName":"Jane Doe","weekday":"Monday","hour":9,"count":3}` +
+		"\n"; rr.Body.String() != want {
+
+		t.Errorf("Incorrect NDJSON export: %q", rr.Body.String())
+	}
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/config"
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/Ahimta/tweeters-stats-golang/services"
+	"github.com/Ahimta/tweeters-stats-golang/sessions"
+	"github.com/Ahimta/tweeters-stats-golang/usecases"
+)
+
+type interactionGraphUsecaseFunc func(
+	ctx context.Context,
+	tweetsService services.TweetsService,
+	accessToken,
+	accessSecret string,
+	window usecases.TimeWindow,
+) (
+	*usecases.InteractionGraphResult, error,
+)
+
+// graphExportFormats are exportFormats (which export the edges) and the
+// graph formats, by format query parameter
+var graphExportFormats = map[string]*exportFormat{
+	"csv":     exportFormats["csv"],
+	"tsv":     exportFormats["tsv"],
+	"ndjson":  exportFormats["ndjson"],
+	"graphml": {"application/graphml+xml", "graphml", writeGraphTable(writeGraphML)},
+	"dot":     {"text/vnd.graphviz", "dot", writeGraphTable(writeGraphDOT)},
+}
+
+// graphTable exports a graph one row per edge, graph formats write the whole
+// graph instead
+type graphTable struct {
+	graph *entities.Graph
+}
+
+func (table graphTable) len() int { return len(table.graph.Edges) }
+
+func (table graphTable) header() []string {
+	return []string{"source", "target", "weight", "replies", "mentions", "retweets"}
+}
+
+func (table graphTable) row(i int) []string {
+	edge := table.graph.Edges[i]
+
+	return []string{
+		edge.Source,
+		edge.Target,
+		formatUint(edge.Weight),
+		formatUint(edge.Replies),
+		formatUint(edge.Mentions),
+		formatUint(edge.Retweets),
+	}
+}
+
+func (table graphTable) item(i int) interface{} { return &table.graph.Edges[i] }
+
+// writeGraphTable adapts write to graphTables, graphExportFormats only ever
+// get those
+func writeGraphTable(
+	write func(w io.Writer, graph *entities.Graph) error,
+) func(io.Writer, statsTable) error {
+
+	return func(w io.Writer, table statsTable) error {
+		return write(w, table.(graphTable).graph)
+	}
+}
+
+// InteractionGraphResponse blablabla
+type InteractionGraphResponse struct {
+	Data *entities.Graph `json:"data"`
+
+	PagesCount  uint `json:"pagesCount"`
+	TweetsCount uint `json:"tweetsCount"`
+}
+
+// InteractionGraph serves who replies to, mentions and retweets whom as JSON
+// (the default), GraphML (e.g., for Gephi), Graphviz DOT or an edge list (per
+// the format query parameter or Accept)
+func InteractionGraph(
+	usecase interactionGraphUsecaseFunc,
+	c *config.Config,
+	service services.TweetsService,
+	store sessions.Store) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		window, err := timeWindow(query, time.Now())
+
+		if err != nil {
+			writeError(w, r, &entities.InvalidInputError{Message: err.Error()})
+			return
+		}
+
+		format, err := negotiateExport(w, r, graphExportFormats)
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		ctx, cancel := requestContext(c, r)
+		defer cancel()
+
+		accessToken, accessSecret := sessionTokens(c, r, store)
+		result, err := usecase(ctx, service, accessToken, accessSecret, window)
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// only JSON has room for the timeline's counts
+		writeExport(w, r, format, graphTable{result.Graph}, &InteractionGraphResponse{
+			Data:        result.Graph,
+			PagesCount:  result.PagesCount,
+			TweetsCount: result.TweetsCount,
+		})
+	}
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+var graphMLKeys = []graphMLKey{
+	{"username", "node", "username", "string"},
+	{"fullName", "node", "fullName", "string"},
+	{"tweetsCount", "node", "tweetsCount", "int"},
+	{"weight", "edge", "weight", "int"},
+	{"replies", "edge", "replies", "int"},
+	{"mentions", "edge", "mentions", "int"},
+	{"retweets", "edge", "retweets", "int"},
+}
+
+func writeGraphML(w io.Writer, graph *entities.Graph) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys:  graphMLKeys,
+		Graph: graphMLGraph{ID: "interactions", EdgeDefault: "directed"},
+	}
+
+	for _, node := range graph.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: node.ID,
+			Data: []graphMLData{
+				{"username", node.Username},
+				{"fullName", node.FullName},
+				{"tweetsCount", fmt.Sprint(node.TweetsCount)},
+			},
+		})
+	}
+
+	for _, edge := range graph.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: edge.Source,
+			Target: edge.Target,
+			Data: []graphMLData{
+				{"weight", fmt.Sprint(edge.Weight)},
+				{"replies", fmt.Sprint(edge.Replies)},
+				{"mentions", fmt.Sprint(edge.Mentions)},
+				{"retweets", fmt.Sprint(edge.Retweets)},
+			},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	return encoder.Encode(&doc)
+}
+
+func writeGraphDOT(w io.Writer, graph *entities.Graph) error {
+	var b strings.Builder
+
+	b.WriteString("digraph interactions {\n")
+
+	for _, node := range graph.Nodes {
+		fmt.Fprintf(
+			&b,
+			"  %s [label=%s, fullName=%s, tweetsCount=%d];\n",
+			dotQuote(node.ID),
+			dotQuote("@"+node.Username),
+			dotQuote(node.FullName),
+			node.TweetsCount,
+		)
+	}
+
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(
+			&b,
+			"  %s -> %s [weight=%d, replies=%d, mentions=%d, retweets=%d];\n",
+			dotQuote(edge.Source),
+			dotQuote(edge.Target),
+			edge.Weight,
+			edge.Replies,
+			edge.Mentions,
+			edge.Retweets,
+		)
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotQuote makes s a DOT quoted string (full names can contain anything)
+func dotQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+
+	return `"` + s + `"`
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/Ahimta/tweeters-stats-golang/services"
+	"github.com/Ahimta/tweeters-stats-golang/sessions"
+	"github.com/Ahimta/tweeters-stats-golang/usecases"
+)
+
+var graphFixture = &entities.Graph{
+	Nodes: []entities.GraphNode{
+		{ID: "jdoe", Username: "jdoe", FullName: `Jane "JD" Doe`, TweetsCount: 1},
+		{ID: "jsmith", Username: "jsmith", FullName: "John Smith", TweetsCount: 2},
+	},
+	Edges: []entities.GraphEdge{
+		{Source: "jsmith", Target: "jdoe", Weight: 2, Replies: 1, Retweets: 1},
+	},
+}
+
+func TestInteractionGraph(t *testing.T) {
+	usecase := func(
+		ctx context.Context,
+		service services.TweetsService,
+		accessToken,
+		accessSecret string,
+		window usecases.TimeWindow,
+	) (
+		*usecases.InteractionGraphResult, error,
+	) {
+
+		if accessToken != "accessToken" || accessSecret != "accessSecret" {
+			t.Errorf("parameters not passed to graph usecase correctly -_-")
+		}
+
+		return &usecases.InteractionGraphResult{
+			Graph:       graphFixture,
+			PagesCount:  1,
+			TweetsCount: 3,
+		}, nil
+	}
+
+	store, sessionCookie := newSession(t)
+	serve := func(target string, accept ...string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", target, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, mediaType := range accept {
+			req.Header.Add("Accept", mediaType)
+		}
+
+		req.AddCookie(sessionCookie)
+
+		rr := httptest.NewRecorder()
+		InteractionGraph(usecase, c, nil, store).ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	//
+	rr := serve("/tweeters-stats/graph")
+	var responseBody InteractionGraphResponse
+	json.NewDecoder(rr.Body).Decode(&responseBody)
+
+	if rr.Code != http.StatusOK ||
+		rr.Header().Get("Content-Type") != "application/json" ||
+		!reflect.DeepEqual(responseBody.Data, graphFixture) ||
+		responseBody.TweetsCount != 3 {
+		t.Errorf("Should return JSON by default: %v", responseBody)
+	}
+
+	//
+	rr = serve("/tweeters-stats/graph?format=graphml")
+
+	if rr.Code != http.StatusOK ||
+		rr.Header().Get("Content-Type") != "application/graphml+xml" {
+		t.Errorf("Should return GraphML: %v", rr.Code)
+	}
+
+	var doc graphML
+	if err := xml.NewDecoder(rr.Body).Decode(&doc); err != nil ||
+		len(doc.Graph.Nodes) != 2 ||
+		len(doc.Graph.Edges) != 1 ||
+		doc.Graph.Edges[0].Source != "jsmith" ||
+		doc.Graph.Edges[0].Data[0].Value != "2" {
+		t.Errorf("Should return valid GraphML: %v, %v", doc, err)
+	}
+
+	//
+	rr = serve("/tweeters-stats/graph?format=dot")
+	body := rr.Body.String()
+
+	if rr.Code != http.StatusOK ||
+		rr.Header().Get("Content-Type") != "text/vnd.graphviz" ||
+		!strings.HasPrefix(body, "digraph interactions {") ||
+		!strings.Contains(body, `"jsmith" -> "jdoe" [weight=2`) ||
+		!strings.Contains(body, `fullName="Jane \"JD\" Doe"`) {
+		t.Errorf("Should return DOT: %v", body)
+	}
+
+	//
+	rr = serve("/tweeters-stats/graph", "application/graphml+xml")
+
+	if rr.Header().Get("Content-Type") != "application/graphml+xml" ||
+		rr.Header().Get("Content-Disposition") != `attachment; filename=graph.graphml` {
+		t.Errorf("Should negotiate GraphML: %v", rr.Header())
+	}
+
+	//
+	rr = serve("/tweeters-stats/graph?format=csv")
+
+	if want := "source,target,weight,replies,mentions,retweets\n" +
+		"jsmith,jdoe,2,1,0,1\n"; rr.Body.String() != want {
+		t.Errorf("Should export the edges as CSV: %q", rr.Body.String())
+	}
+
+	//
+	if rr := serve("/tweeters-stats/graph?format=png"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 HTTP status code for unknown formats: %v", rr.Code)
+	}
+
+	//
+	req, _ := http.NewRequest("GET", "/tweeters-stats/graph", nil)
+	rr = httptest.NewRecorder()
+	InteractionGraph(
+		usecases.InteractionGraph,
+		c,
+		nil,
+		sessions.NewMemoryStore(time.Hour),
+	).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 HTTP status code without a session: %v", rr.Code)
+	}
+}
+
+func Test_dotQuote(t *testing.T) {
+	if got := dotQuote("a\"b\\c\nd"); got != `"a\"b\\c\nd"` {
+		t.Errorf("dotQuote() = %v", got)
+	}
+}
@@ -1,37 +1,138 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/Ahimta/tweeters-stats-golang/auth"
+	"github.com/Ahimta/tweeters-stats-golang/collector"
 	"github.com/Ahimta/tweeters-stats-golang/config"
 	"github.com/Ahimta/tweeters-stats-golang/entities"
 	"github.com/Ahimta/tweeters-stats-golang/services"
+	"github.com/Ahimta/tweeters-stats-golang/sessions"
 	"github.com/Ahimta/tweeters-stats-golang/usecases"
 )
 
-type loginUsecaseFunc func(client auth.Oauth1Client) (
+type loginUsecaseFunc func(
+	ctx context.Context,
+	client auth.Oauth1Client,
+	requestTokens sessions.RequestTokenStore,
+) (
 	*usecases.LoginResult, error,
 )
 
 type oauth1CallbackUsecaseFunc func(
+	ctx context.Context,
 	oauthClient auth.Oauth1Client,
-	requestSecret string,
+	requestTokens sessions.RequestTokenStore,
+	requestToken string,
 	r *http.Request) (
 	*usecases.Oauth1CallbackResult, error,
 )
 
 type tweetersStatsUsecaseFunc func(
+	ctx context.Context,
 	tweetsService services.TweetsService, accessToken,
 	accessSecret string,
+	window usecases.TimeWindow,
+	query usecases.StatsQuery,
+) (
+	*usecases.TweetersStatsResult, error,
+)
+
+type amplifiedStatsUsecaseFunc func(
+	ctx context.Context,
+	tweetsService services.TweetsService, accessToken,
+	accessSecret string,
+	window usecases.TimeWindow,
+) (
+	*usecases.AmplifiedStatsResult, error,
+)
+
+type timeSeriesUsecaseFunc func(
+	ctx context.Context,
+	tweetsService services.TweetsService,
+	accessToken,
+	accessSecret string,
+	window usecases.TimeWindow,
+	query usecases.TimeSeriesQuery,
 ) (
-	[]*entities.TweeterStats, error,
+	*usecases.TimeSeriesResult, error,
 )
 
+type heatmapUsecaseFunc func(
+	ctx context.Context,
+	tweetsService services.TweetsService,
+	accessToken,
+	accessSecret string,
+	window usecases.TimeWindow,
+	location *time.Location,
+) (
+	*usecases.HeatmapResult, error,
+)
+
+type entityStatsUsecaseFunc func(
+	ctx context.Context,
+	tweetsService services.TweetsService,
+	accessToken,
+	accessSecret string,
+	window usecases.TimeWindow,
+	query usecases.StatsQuery,
+) (
+	*usecases.EntityStatsResult, error,
+)
+
+type rateLimitUsecaseFunc func(
+	tweetsService services.TweetsService,
+	accessToken,
+	accessSecret string,
+) (
+	*entities.RateLimit, error,
+)
+
+type archiveTweetersStatsUsecaseFunc func(
+	ctx context.Context,
+	tweetsService services.TweetsService,
+	window usecases.TimeWindow,
+) (
+	*usecases.TweetersStatsResult, error,
+)
+
+type archiveServiceFunc func(r io.ReaderAt, size int64) services.TweetsService
+
+// uploaded archives bigger than this are kept on disk while being processed
+const maxArchiveMemory = 32 << 20
+
+// uploads bigger than this are rejected before being read completely
+var maxArchiveUpload int64 = 128 << 20
+
+// loginErrorCodes are sent to the homepage (as the loginError query
+// parameter) when a login fails, Twitter being unreachable is reported as
+// "unavailable" and anything else as "failed"
+var loginErrorCodes = map[error]string{
+	usecases.ErrLoginMissing:  "missing",
+	usecases.ErrLoginMismatch: "mismatch",
+	usecases.ErrLoginExpired:  "expired",
+	usecases.ErrLoginReplayed: "replayed",
+	usecases.ErrLoginDenied:   "denied",
+}
+
+// presets accepted by the window query parameter (besides Go durations)
+var windowPresets = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+}
+
 // HealthCheck blablabla
 func HealthCheck() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -45,8 +146,7 @@ func Homepage(indexHTMLPath string) http.HandlerFunc {
 		data, err := ioutil.ReadFile(indexHTMLPath)
 
 		if err != nil {
-			log.Println(err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
 
@@ -57,57 +157,83 @@ func Homepage(indexHTMLPath string) http.HandlerFunc {
 // Login blablabla
 func Login(
 	usecase loginUsecaseFunc,
+	c *config.Config,
 	client auth.Oauth1Client,
+	requestTokens sessions.RequestTokenStore,
 ) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
-			w.WriteHeader(http.StatusMethodNotAllowed)
+			writeMethodNotAllowed(w, r)
 			return
 		}
 
-		result, err := usecase(client)
+		ctx, cancel := requestContext(c, r)
+		defer cancel()
+
+		result, err := usecase(ctx, client, requestTokens)
 
 		if err != nil {
-			fmt.Println(err)
-			http.Redirect(w, r, "/", http.StatusFound)
+			log.Println(err)
+			http.Redirect(w, r, loginErrorURL(c.Homepage, err), http.StatusFound)
 			return
 		}
 
-		http.SetCookie(w, &http.Cookie{
-			Name:  "oauthRequestSecret",
-			Value: result.RequestSecret,
-			Path:  "/",
-		})
+		cookie, err := newCookie(
+			c,
+			requestTokenCookie,
+			result.RequestToken,
+			int(usecases.LoginTTL/time.Second),
+		)
 
+		if err != nil {
+			log.Println(err)
+			http.Redirect(w, r, loginErrorURL(c.Homepage, err), http.StatusFound)
+			return
+		}
+
+		http.SetCookie(w, cookie)
 		http.Redirect(w, r, result.AuthorizationURL.String(), http.StatusFound)
 	}
 }
 
-// Logout blablabla
-func Logout() http.HandlerFunc {
+// Logout destroys the server-side session (if any) and clears all cookies,
+// the account is opted out of background collection too (col can be nil when
+// collecting is disabled) so its tokens aren't used after logging out
+func Logout(
+	c *config.Config,
+	store sessions.Store,
+	col *collector.Collector) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodDelete {
-			w.WriteHeader(http.StatusMethodNotAllowed)
+			writeMethodNotAllowed(w, r)
 			return
 		}
 
-		http.SetCookie(w, &http.Cookie{
-			Name:  "oauthRequestSecret",
-			Value: "",
-			Path:  "/",
-		})
-		http.SetCookie(w, &http.Cookie{
-			Name:  "accessToken",
-			Value: "",
-			Path:  "/",
-		})
-		http.SetCookie(w, &http.Cookie{
-			Name:  "accessSecret",
-			Value: "",
-			Path:  "/",
-		})
+		if id := cookieValue(c, r, sessionCookie); id != "" {
+			if session, err := store.Get(id); err == nil && col != nil {
+				if err := col.OptOut(session.AccessToken); err != nil {
+					writeError(w, r, err)
+					return
+				}
+			}
+
+			if err := store.Delete(id); err != nil {
+				writeError(w, r, err)
+				return
+			}
+		}
+
+		for _, name := range []string{
+			requestTokenCookie,
+			sessionCookie,
+			legacyRequestSecretCookie,
+			legacyAccessTokenCookie,
+			legacyAccessSecretCookie,
+		} {
+			http.SetCookie(w, expiredCookie(c, name))
+		}
 
 		w.WriteHeader(http.StatusNoContent)
 	}
@@ -118,29 +244,44 @@ func OauthTwitter(
 	usecase oauth1CallbackUsecaseFunc,
 	c *config.Config,
 	client auth.Oauth1Client,
+	requestTokens sessions.RequestTokenStore,
+	store sessions.Store,
 ) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		requestSecret := cookieValue(r, "oauthRequestSecret")
-		result, err := usecase(client, requestSecret, r)
+		ctx, cancel := requestContext(c, r)
+		defer cancel()
+
+		requestToken := cookieValue(c, r, requestTokenCookie)
+		result, err := usecase(ctx, client, requestTokens, requestToken, r)
+
+		// the request token can't be used again either way
+		http.SetCookie(w, expiredCookie(c, requestTokenCookie))
 
 		if err != nil {
-			fmt.Println(err)
-			http.Redirect(w, r, c.Homepage, http.StatusFound)
+			log.Println(err)
+			http.Redirect(w, r, loginErrorURL(c.Homepage, err), http.StatusFound)
 			return
 		}
 
-		http.SetCookie(w, &http.Cookie{
-			Name:  "accessToken",
-			Value: result.AccessToken,
-			Path:  "/",
-		})
+		id, err := store.Create(result.AccessToken, result.AccessSecret)
 
-		http.SetCookie(w, &http.Cookie{
-			Name:  "accessSecret",
-			Value: result.AccessSecret,
-			Path:  "/",
-		})
+		if err != nil {
+			log.Println(err)
+			http.Redirect(w, r, loginErrorURL(c.Homepage, err), http.StatusFound)
+			return
+		}
+
+		maxAge := int(c.SessionTTL / time.Second)
+		cookie, err := newCookie(c, sessionCookie, id, maxAge)
+
+		if err != nil {
+			log.Println(err)
+			http.Redirect(w, r, loginErrorURL(c.Homepage, err), http.StatusFound)
+			return
+		}
+
+		http.SetCookie(w, cookie)
 
 		http.Redirect(w, r, c.Homepage, http.StatusFound)
 	}
@@ -149,39 +290,600 @@ func OauthTwitter(
 // TweetersStatsResponse blablabla
 type TweetersStatsResponse struct {
 	Data []*entities.TweeterStats `json:"data"`
+
+	// how many tweeters there are before limit and offset
+	TotalCount uint                   `json:"totalCount"`
+	Summary    *entities.StatsSummary `json:"summary"`
+
+	PagesCount  uint                `json:"pagesCount"`
+	TweetsCount uint                `json:"tweetsCount"`
+	RateLimit   *entities.RateLimit `json:"rateLimit,omitempty"`
+
+	// the rate limit ran out while fetching, so older tweets are missing
+	Truncated bool `json:"truncated"`
 }
 
-// TweetersStats blablabla
+// TweetersStats responds with a 304 when the stats didn't change since the
+// client's copy, refresh=true skips the timelines cache, sort, order, limit
+// and offset sort and page the tweeters
 func TweetersStats(
 	usecase tweetersStatsUsecaseFunc,
-	service services.TweetsService) http.HandlerFunc {
+	c *config.Config,
+	service services.TweetsService,
+	store sessions.Store) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
-			w.WriteHeader(http.StatusMethodNotAllowed)
+			writeMethodNotAllowed(w, r)
+			return
+		}
+
+		window, err := timeWindow(r.URL.Query(), time.Now())
+
+		if err != nil {
+			writeError(w, r, &entities.InvalidInputError{Message: err.Error()})
+			return
+		}
+
+		query, err := statsQuery(r.URL.Query())
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		format, err := negotiateExport(w, r, exportFormats)
+
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		accessToken := cookieValue(r, "accessToken")
-		accessSecret := cookieValue(r, "accessSecret")
-		stats, err := usecase(service, accessToken, accessSecret)
+		tweetsService := service
+		if r.URL.Query().Get("refresh") == "true" {
+			tweetsService = services.Refreshing(service)
+		}
+
+		ctx, cancel := requestContext(c, r)
+		defer cancel()
+
+		accessToken, accessSecret := sessionTokens(c, r, store)
+		result, err := usecase(
+			ctx,
+			tweetsService,
+			accessToken,
+			accessSecret,
+			window,
+			query,
+		)
 
 		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusUnauthorized)
+			writeError(w, r, err)
 			return
 		}
 
-		json.NewEncoder(w).Encode(&TweetersStatsResponse{stats})
+		lastModified := result.FetchedAt
+		if lastModified.IsZero() {
+			lastModified = time.Now()
+		}
+
+		if format != nil {
+			data, err := encodeExport(w, r, format, tweeterStatsTable(result.Stats))
+
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+
+			writeConditional(w, r, format.contentType, data, lastModified)
+			return
+		}
+
+		writeConditionalJSON(w, r, tweetersStatsResponse(result), lastModified)
 	}
 }
 
-func cookieValue(r *http.Request, name string) string {
-	cookie, err := r.Cookie(name)
+// RateLimitResponse blablabla
+type RateLimitResponse struct {
+	// null when unknown
+	Data *entities.RateLimit `json:"data"`
+}
+
+// RateLimit returns the remaining Twitter quota of the session's access token
+// without spending any of it
+func RateLimit(
+	usecase rateLimitUsecaseFunc,
+	c *config.Config,
+	service services.TweetsService,
+	store sessions.Store) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, r)
+			return
+		}
+
+		accessToken, accessSecret := sessionTokens(c, r, store)
+		rateLimit, err := usecase(service, accessToken, accessSecret)
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		json.NewEncoder(w).Encode(&RateLimitResponse{Data: rateLimit})
+	}
+}
+
+// ArchiveTweetersStats computes tweeters stats for an uploaded Twitter archive
+// ZIP (sent as the "archive" field of a multipart form), only logged in users
+// can upload archives even though they aren't read with their access tokens
+func ArchiveTweetersStats(
+	usecase archiveTweetersStatsUsecaseFunc,
+	newService archiveServiceFunc,
+	c *config.Config,
+	store sessions.Store) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w, r)
+			return
+		}
+
+		if accessToken, _ := sessionTokens(c, r, store); accessToken == "" {
+			writeError(w, r, &entities.UnauthenticatedError{
+				Reason: "session missing -_-",
+			})
+			return
+		}
+
+		window, err := timeWindow(r.URL.Query(), time.Now())
+
+		if err != nil {
+			writeError(w, r, &entities.InvalidInputError{Message: err.Error()})
+			return
+		}
+
+		format, err := negotiateExport(w, r, exportFormats)
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxArchiveUpload)
+		if err := r.ParseMultipartForm(maxArchiveMemory); err != nil {
+			writeError(w, r, &entities.InvalidInputError{Message: err.Error()})
+			return
+		}
+
+		defer r.MultipartForm.RemoveAll()
+		file, header, err := r.FormFile("archive")
+
+		if err != nil {
+			writeError(w, r, &entities.InvalidInputError{Message: err.Error()})
+			return
+		}
+
+		defer file.Close()
+		result, err := usecase(r.Context(), newService(file, header.Size), window)
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		writeExport(w, r, format, tweeterStatsTable(result.Stats), &TweetersStatsResponse{
+			Data:        result.Stats,
+			TotalCount:  result.TotalCount,
+			Summary:     result.Summary,
+			PagesCount:  result.PagesCount,
+			TweetsCount: result.TweetsCount,
+		})
+	}
+}
+
+// AmplifiedStatsResponse blablabla
+type AmplifiedStatsResponse struct {
+	Data []*entities.AmplifiedStats `json:"data"`
+
+	PagesCount  uint `json:"pagesCount"`
+	TweetsCount uint `json:"tweetsCount"`
+}
+
+// AmplifiedStats blablabla
+func AmplifiedStats(
+	usecase amplifiedStatsUsecaseFunc,
+	c *config.Config,
+	service services.TweetsService,
+	store sessions.Store) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, r)
+			return
+		}
+
+		window, err := timeWindow(r.URL.Query(), time.Now())
+
+		if err != nil {
+			writeError(w, r, &entities.InvalidInputError{Message: err.Error()})
+			return
+		}
+
+		format, err := negotiateExport(w, r, exportFormats)
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		ctx, cancel := requestContext(c, r)
+		defer cancel()
+
+		accessToken, accessSecret := sessionTokens(c, r, store)
+		result, err := usecase(ctx, service, accessToken, accessSecret, window)
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		writeExport(w, r, format, amplifiedStatsTable(result.Stats), &AmplifiedStatsResponse{
+			Data:        result.Stats,
+			PagesCount:  result.PagesCount,
+			TweetsCount: result.TweetsCount,
+		})
+	}
+}
+
+// TimeSeriesResponse blablabla
+type TimeSeriesResponse struct {
+	Data  []*entities.TweeterTimeSeries `json:"data"`
+	Total []entities.TimeSeriesPoint    `json:"total"`
+
+	Bucket      usecases.TimeBucket `json:"bucket"`
+	PagesCount  uint                `json:"pagesCount"`
+	TweetsCount uint                `json:"tweetsCount"`
+}
+
+// TimeSeries counts tweets per tweeter per hour or day (the bucket query
+// parameter, day by default), username restricts it to a single tweeter
+func TimeSeries(
+	usecase timeSeriesUsecaseFunc,
+	c *config.Config,
+	service services.TweetsService,
+	store sessions.Store) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		window, err := timeWindow(query, time.Now())
+
+		if err != nil {
+			writeError(w, r, &entities.InvalidInputError{Message: err.Error()})
+			return
+		}
+
+		bucket := usecases.DayBucket
+		if query.Get("bucket") != "" {
+			bucket, err = usecases.ParseTimeBucket(query.Get("bucket"))
+
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+		}
+
+		format, err := negotiateExport(w, r, exportFormats)
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		ctx, cancel := requestContext(c, r)
+		defer cancel()
+
+		accessToken, accessSecret := sessionTokens(c, r, store)
+		result, err := usecase(
+			ctx,
+			service,
+			accessToken,
+			accessSecret,
+			window,
+			usecases.TimeSeriesQuery{
+				Bucket:   bucket,
+				Username: query.Get("username"),
+			},
+		)
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		writeExport(w, r, format, newTimeSeriesTable(result.Series), &TimeSeriesResponse{
+			Data:        result.Series,
+			Total:       result.Total,
+			Bucket:      bucket,
+			PagesCount:  result.PagesCount,
+			TweetsCount: result.TweetsCount,
+		})
+	}
+}
+
+// HeatmapResponse blablabla
+type HeatmapResponse struct {
+	Data  []*entities.TweeterHeatmap `json:"data"`
+	Total entities.Heatmap           `json:"total"`
+
+	Timezone    string `json:"timezone"`
+	PagesCount  uint   `json:"pagesCount"`
+	TweetsCount uint   `json:"tweetsCount"`
+}
+
+// Heatmap counts tweets by weekday and hour of day in the timezone query
+// parameter (an IANA name, UTC by default)
+func Heatmap(
+	usecase heatmapUsecaseFunc,
+	c *config.Config,
+	service services.TweetsService,
+	store sessions.Store) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		window, err := timeWindow(query, time.Now())
+
+		if err != nil {
+			writeError(w, r, &entities.InvalidInputError{Message: err.Error()})
+			return
+		}
+
+		location, err := time.LoadLocation(query.Get("timezone"))
+
+		if err != nil {
+			writeError(w, r, &entities.InvalidInputError{Message: err.Error()})
+			return
+		}
+
+		format, err := negotiateExport(w, r, exportFormats)
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		ctx, cancel := requestContext(c, r)
+		defer cancel()
+
+		accessToken, accessSecret := sessionTokens(c, r, store)
+		result, err := usecase(ctx, service, accessToken, accessSecret, window, location)
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		writeExport(w, r, format, newHeatmapTable(result.Heatmaps), &HeatmapResponse{
+			Data:        result.Heatmaps,
+			Total:       result.Total,
+			Timezone:    location.String(),
+			PagesCount:  result.PagesCount,
+			TweetsCount: result.TweetsCount,
+		})
+	}
+}
+
+// EntityStatsResponse blablabla
+type EntityStatsResponse struct {
+	Data       []*entities.EntityStats `json:"data"`
+	TotalCount uint                    `json:"totalCount"`
+
+	PagesCount  uint `json:"pagesCount"`
+	TweetsCount uint `json:"tweetsCount"`
+}
+
+// EntityStats serves a ranking of hashtags, mentioned users or domains (i.e.,
+// whichever usecase is passed), sorted and paged like TweetersStats
+func EntityStats(
+	usecase entityStatsUsecaseFunc,
+	c *config.Config,
+	service services.TweetsService,
+	store sessions.Store) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, r)
+			return
+		}
+
+		window, err := timeWindow(r.URL.Query(), time.Now())
+
+		if err != nil {
+			writeError(w, r, &entities.InvalidInputError{Message: err.Error()})
+			return
+		}
+
+		query, err := statsQuery(r.URL.Query())
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		format, err := negotiateExport(w, r, exportFormats)
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		ctx, cancel := requestContext(c, r)
+		defer cancel()
+
+		accessToken, accessSecret := sessionTokens(c, r, store)
+		result, err := usecase(ctx, service, accessToken, accessSecret, window, query)
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		writeExport(w, r, format, entityStatsTable(result.Stats), &EntityStatsResponse{
+			Data:        result.Stats,
+			TotalCount:  result.TotalCount,
+			PagesCount:  result.PagesCount,
+			TweetsCount: result.TweetsCount,
+		})
+	}
+}
+
+// requestContext is cancelled when the client goes away or, unless it's
+// zero, after c's RequestTimeout
+func requestContext(c *config.Config, r *http.Request) (
+	context.Context, context.CancelFunc,
+) {
+
+	if c.RequestTimeout <= 0 {
+		return context.WithCancel(r.Context())
+	}
+
+	return context.WithTimeout(r.Context(), c.RequestTimeout)
+}
+
+// queryLimit parses the limit query parameter, zero (the default) means no
+// limit
+func queryLimit(query url.Values) (int, error) {
+	return nonNegativeQueryInt(query, "limit")
+}
+
+func nonNegativeQueryInt(query url.Values, name string) (int, error) {
+	if query.Get(name) == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(query.Get(name))
+
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("handlers: %s must be a non-negative integer", name)
+	}
+
+	return n, nil
+}
+
+// statsQuery parses the sort, order, limit and offset query parameters
+func statsQuery(query url.Values) (usecases.StatsQuery, error) {
+	limit, err := queryLimit(query)
+
+	if err != nil {
+		return usecases.StatsQuery{}, &entities.InvalidInputError{Message: err.Error()}
+	}
+
+	offset, err := nonNegativeQueryInt(query, "offset")
 
 	if err != nil {
-		return ""
+		return usecases.StatsQuery{}, &entities.InvalidInputError{Message: err.Error()}
+	}
+
+	return usecases.ParseStatsQuery(
+		query.Get("sort"),
+		query.Get("order"),
+		limit,
+		offset,
+	)
+}
+
+// loginErrorURL adds a loginError query parameter to homepage, so the
+// frontend can tell users why their login failed
+func loginErrorURL(homepage string, err error) string {
+	u, parseErr := url.Parse(homepage)
+
+	if parseErr != nil {
+		return homepage
+	}
+
+	code, ok := loginErrorCodes[err]
+	if _, upstream := err.(*entities.UpstreamError); upstream {
+		code = "unavailable"
+	} else if !ok {
+		code = "failed"
+	}
+
+	query := u.Query()
+	query.Set("loginError", code)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// timeWindow parses either a relative window (e.g., window=24h or window=day)
+// or absolute RFC 3339 since/until bounds from the query string
+func timeWindow(query url.Values, now time.Time) (usecases.TimeWindow, error) {
+	window := usecases.TimeWindow{}
+	preset := query.Get("window")
+	since := query.Get("since")
+	until := query.Get("until")
+
+	if preset != "" {
+		if since != "" || until != "" {
+			return window, errors.New(
+				"handlers: window can't be combined with since or until",
+			)
+		}
+
+		duration, ok := windowPresets[preset]
+		if !ok {
+			var err error
+			duration, err = time.ParseDuration(preset)
+
+			if err != nil {
+				return window, err
+			}
+		}
+
+		if duration <= 0 {
+			return window, errors.New("handlers: window must be positive")
+		}
+
+		window.Since = now.Add(-duration)
+		return window, nil
+	}
+
+	if since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+
+		if err != nil {
+			return window, err
+		}
+
+		window.Since = t
+	}
+
+	if until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+
+		if err != nil {
+			return window, err
+		}
+
+		window.Until = t
+	}
+
+	if !window.Since.IsZero() &&
+		!window.Until.IsZero() &&
+		!window.Since.Before(window.Until) {
+		return window, errors.New("handlers: since must be before until")
 	}
 
-	return cookie.Value
+	return window, nil
 }
@@ -1,21 +1,62 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Ahimta/tweeters-stats-golang/auth"
 	"github.com/Ahimta/tweeters-stats-golang/config"
 	"github.com/Ahimta/tweeters-stats-golang/entities"
 	"github.com/Ahimta/tweeters-stats-golang/services"
+	"github.com/Ahimta/tweeters-stats-golang/sessions"
 	"github.com/Ahimta/tweeters-stats-golang/usecases"
 )
 
+var c, _ = config.New(func(key string) string {
+	return map[string]string{
+		"CONSUMER_KEY":    "consumerKey",
+		"CONSUMER_SECRET": "consumerSecret",
+		"CALLBACK_URL":    "callbackURL",
+		"PORT":            "80",
+		"HOMEPAGE":        "/",
+		"HOST":            "localhost",
+		"PROTOCOL":        "http",
+		"COOKIE_KEY":      "a2tra2tra2tra2tra2tra2tra2tra2tra2tra2tra2s=",
+	}[key]
+})
+
+func newSession(t *testing.T) (sessions.Store, *http.Cookie) {
+	store := sessions.NewMemoryStore(time.Hour)
+	id, err := store.Create("accessToken", "accessSecret")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store, sealedCookie(t, "session", id)
+}
+
+func sealedCookie(t *testing.T, name, value string) *http.Cookie {
+	cookie, err := newCookie(c, name, value, 3600)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cookie
+}
+
 func TestHealthCheck(t *testing.T) {
 	req, err := http.NewRequest("GET", "/health-check", nil)
 
@@ -69,25 +110,34 @@ func TestLogin(t *testing.T) {
 				"consumerKey",
 				"consumerSecret",
 				"callbackURL",
+				auth.TwitterEndpoint(auth.DefaultTwitterURL),
 			)
 
 			if err != nil {
 				t.Fatal(err)
 			}
 
-			usecase := func(client auth.Oauth1Client) (*usecases.LoginResult, error) {
-				if client != oauthClient {
-					t.Errorf("oauthClient not passed to login usecase -_-")
+			requestTokens := sessions.NewRequestTokenStore(time.Minute)
+			usecase := func(
+				ctx context.Context,
+				client auth.Oauth1Client,
+				tokens sessions.RequestTokenStore,
+			) (*usecases.LoginResult, error) {
+
+				if ctx == nil || client != oauthClient || tokens != requestTokens {
+					t.Errorf("parameters not passed to login usecase correctly -_-")
 				}
 
 				return &usecases.LoginResult{
 					AuthorizationURL: authorizationURL,
-					RequestSecret:    "requestSecret",
+					RequestToken:     "requestToken",
 				}, nil
 			}
 
 			rr := httptest.NewRecorder()
-			handler := http.HandlerFunc(Login(usecase, oauthClient))
+			handler := http.HandlerFunc(
+				Login(usecase, c, oauthClient, requestTokens),
+			)
 			handler.ServeHTTP(rr, req)
 
 			if status := rr.Code; status != http.StatusFound {
@@ -95,10 +145,23 @@ func TestLogin(t *testing.T) {
 			}
 
 			setCookie := rr.Header().Get("Set-Cookie")
-			if setCookie != "oauthRequestSecret=requestSecret; Path=/" {
+			if !strings.HasPrefix(setCookie, "oauthRequestToken=") ||
+				!strings.HasSuffix(
+					setCookie,
+					"; Path=/; Max-Age=600; HttpOnly; SameSite=Lax",
+				) ||
+				strings.Contains(setCookie, "requestToken;") {
 				t.Errorf("Incorrect Set-Cookie value: %v", setCookie)
 			}
 
+			callbackReq := &http.Request{Header: http.Header{}}
+			callbackReq.AddCookie(rr.Result().Cookies()[0])
+			requestToken := cookieValue(c, callbackReq, "oauthRequestToken")
+
+			if requestToken != "requestToken" {
+				t.Errorf("Should seal the request token: %v", requestToken)
+			}
+
 			location := rr.Header().Get("Location")
 			if location != authorizationURL.String() {
 				t.Errorf(
@@ -123,12 +186,17 @@ func TestLogin(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			usecase := func(client auth.Oauth1Client) (*usecases.LoginResult, error) {
-				return nil, errors.New("whaaat -_-")
+			usecase := func(
+				ctx context.Context,
+				client auth.Oauth1Client,
+				tokens sessions.RequestTokenStore,
+			) (*usecases.LoginResult, error) {
+
+				return nil, &entities.UpstreamError{Err: errors.New("whaaat -_-")}
 			}
 
 			rr := httptest.NewRecorder()
-			handler := http.HandlerFunc(Login(usecase, nil))
+			handler := http.HandlerFunc(Login(usecase, c, nil, nil))
 			handler.ServeHTTP(rr, req)
 
 			if status := rr.Code; status != http.StatusFound {
@@ -139,42 +207,65 @@ func TestLogin(t *testing.T) {
 				t.Errorf("Incorrect Set-Cookie value: %v", setCookie)
 			}
 
-			if location := rr.Header().Get("Location"); location != "/" {
-				t.Errorf("Incorrect Location value: %v != %v", location, "/")
+			location := rr.Header().Get("Location")
+			if location != "/?loginError=unavailable" {
+				t.Errorf("Incorrect Location value: %v", location)
 			}
 		})
 }
 
-func TestOauthTwitter(t *testing.T) {
-	c, err := config.New(
-		"consumerKey",
-		"consumerSecret",
-		"callbackURL",
-		"80",
-		"/",
-		"localhost",
-		"http",
-		"",
-	)
+func TestLogout(t *testing.T) {
+	store := sessions.NewMemoryStore(time.Hour)
+	id, err := store.Create("accessToken", "accessSecret")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("DELETE", "/logout", nil)
 
 	if err != nil {
-		t.Errorf(err.Error())
+		t.Fatal(err)
 	}
 
+	req.AddCookie(sealedCookie(t, "session", id))
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(Logout(c, store, nil))
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusNoContent {
+		t.Errorf("Expected 204 HTTP status code")
+	}
+
+	if _, err := store.Get(id); err != sessions.ErrNotFound {
+		t.Errorf("Should destroy the session")
+	}
+
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Value != "" || cookie.MaxAge != -1 {
+			t.Errorf("Should clear all cookies: %v", cookie)
+		}
+	}
+
+	if cookies := rr.Result().Cookies(); len(cookies) != 5 {
+		t.Errorf("Should clear all cookies: %v", cookies)
+	}
+}
+
+func TestOauthTwitter(t *testing.T) {
 	t.Run(
 		"should use underlying implementation and redirect to correct URL",
 		func(t *testing.T) {
 
 			req, err := http.NewRequest("GET", "/oauth/twitter/callback", nil)
-			req.AddCookie(&http.Cookie{
-				Name:  "oauthRequestSecret",
-				Value: "requestSecret",
-			})
 
 			if err != nil {
 				t.Fatal(err)
 			}
 
+			req.AddCookie(sealedCookie(t, "oauthRequestToken", "requestToken"))
+
 			result := &usecases.Oauth1CallbackResult{
 				AccessToken:  "accessToken",
 				AccessSecret: "accessSecret",
@@ -184,21 +275,27 @@ func TestOauthTwitter(t *testing.T) {
 				"consumerKey",
 				"consumerSecret",
 				"callbackURL",
+				auth.TwitterEndpoint(auth.DefaultTwitterURL),
 			)
 
 			if err != nil {
 				t.Fatal(err)
 			}
 
+			requestTokens := sessions.NewRequestTokenStore(time.Minute)
 			usecase := func(
+				ctx context.Context,
 				client auth.Oauth1Client,
-				requestSecret string,
+				tokens sessions.RequestTokenStore,
+				requestToken string,
 				r *http.Request) (
 				*usecases.Oauth1CallbackResult, error,
 			) {
 
-				if client != oauthClient ||
-					requestSecret != "requestSecret" ||
+				if ctx == nil ||
+					client != oauthClient ||
+					tokens != requestTokens ||
+					requestToken != "requestToken" ||
 					r == nil {
 
 					t.Errorf("parameters not passed to oauth usecase correctly -_-")
@@ -207,17 +304,41 @@ func TestOauthTwitter(t *testing.T) {
 				return result, nil
 			}
 
+			store := sessions.NewMemoryStore(time.Hour)
 			rr := httptest.NewRecorder()
-			handler := http.HandlerFunc(OauthTwitter(usecase, c, oauthClient))
+			handler := http.HandlerFunc(
+				OauthTwitter(usecase, c, oauthClient, requestTokens, store),
+			)
 			handler.ServeHTTP(rr, req)
 
 			if status := rr.Code; status != http.StatusFound {
 				t.Errorf("Expected 302 HTTP status code")
 			}
 
-			setCookie := rr.Header().Get("Set-Cookie")
-			if setCookie != "accessToken=accessToken; Path=/" {
-				t.Errorf("Incorrect Set-Cookie value: %v", setCookie)
+			cookies := rr.Result().Cookies()
+			if len(cookies) != 2 ||
+				cookies[0].Name != "oauthRequestToken" ||
+				cookies[0].MaxAge != -1 ||
+				cookies[1].Name != "session" ||
+				!cookies[1].HttpOnly {
+				t.Errorf("Incorrect Set-Cookie value: %v", cookies)
+			}
+
+			sessionReq := &http.Request{Header: http.Header{}}
+			sessionReq.AddCookie(cookies[1])
+
+			session, err := store.Get(cookieValue(c, sessionReq, "session"))
+			if err != nil ||
+				session.AccessToken != "accessToken" ||
+				session.AccessSecret != "accessSecret" {
+				t.Errorf("Should keep the access token server-side: %v", session)
+			}
+
+			for _, cookie := range cookies {
+				if strings.Contains(cookie.Value, "accessToken") ||
+					strings.Contains(cookie.Value, "accessSecret") {
+					t.Errorf("Should not leak the access token: %v", cookie)
+				}
 			}
 
 			location := rr.Header().Get("Location")
@@ -231,39 +352,55 @@ func TestOauthTwitter(t *testing.T) {
 		})
 
 	t.Run(
-		"should use underlying implementation and redirect to correct URL",
+		"should redirect with the reason when the login fails",
 		func(t *testing.T) {
 
-			req, err := http.NewRequest("GET", "/oauth/twitter/callback", nil)
-
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			usecase := func(
-				client auth.Oauth1Client,
-				requestSecret string,
-				r *http.Request) (
-				*usecases.Oauth1CallbackResult, error,
-			) {
+			for err, code := range map[error]string{
+				usecases.ErrLoginMissing:  "missing",
+				usecases.ErrLoginMismatch: "mismatch",
+				usecases.ErrLoginExpired:  "expired",
+				usecases.ErrLoginReplayed: "replayed",
+				usecases.ErrLoginDenied:   "denied",
+				errors.New("whaaat -_-"):  "failed",
+			} {
+				req, reqErr := http.NewRequest("GET", "/oauth/twitter/callback", nil)
+
+				if reqErr != nil {
+					t.Fatal(reqErr)
+				}
 
-				return nil, errors.New("whaaat -_-")
-			}
+				usecaseErr := err
+				usecase := func(
+					ctx context.Context,
+					client auth.Oauth1Client,
+					tokens sessions.RequestTokenStore,
+					requestToken string,
+					r *http.Request) (
+					*usecases.Oauth1CallbackResult, error,
+				) {
+
+					return nil, usecaseErr
+				}
 
-			rr := httptest.NewRecorder()
-			handler := http.HandlerFunc(OauthTwitter(usecase, c, nil))
-			handler.ServeHTTP(rr, req)
+				rr := httptest.NewRecorder()
+				handler := http.HandlerFunc(OauthTwitter(usecase, c, nil, nil, nil))
+				handler.ServeHTTP(rr, req)
 
-			if status := rr.Code; status != http.StatusFound {
-				t.Errorf("Expected 302 HTTP status code")
-			}
+				if status := rr.Code; status != http.StatusFound {
+					t.Errorf("Expected 302 HTTP status code")
+				}
 
-			if setCookie := rr.Header().Get("Set-Cookie"); setCookie != "" {
-				t.Errorf("Incorrect Set-Cookie value: %v", setCookie)
-			}
+				cookies := rr.Result().Cookies()
+				if len(cookies) != 1 ||
+					cookies[0].Name != "oauthRequestToken" ||
+					cookies[0].MaxAge != -1 {
+					t.Errorf("Should clear the request token: %v", cookies)
+				}
 
-			if location := rr.Header().Get("Location"); location != c.Homepage {
-				t.Errorf("Incorrect Location value: %v != %v", location, c.Homepage)
+				location := rr.Header().Get("Location")
+				if location != "/?loginError="+code {
+					t.Errorf("Incorrect Location value: %v (%v)", location, err)
+				}
 			}
 		})
 }
@@ -274,44 +411,51 @@ func TestTweetersStats(t *testing.T) {
 		func(t *testing.T) {
 
 			req, err := http.NewRequest("GET", "/tweeters-stats", nil)
-			req.AddCookie(&http.Cookie{
-				Name:  "accessToken",
-				Value: "accessToken",
-			})
-			req.AddCookie(&http.Cookie{
-				Name:  "accessSecret",
-				Value: "accessSecret",
-			})
 
 			if err != nil {
 				t.Fatal(err)
 			}
 
-			result := []*entities.TweeterStats{
-				&entities.TweeterStats{
-					FullName:    "John Smith",
-					Username:    "jsmith",
-					TweetsCount: 3,
+			store, sessionCookie := newSession(t)
+			req.AddCookie(sessionCookie)
+
+			result := &usecases.TweetersStatsResult{
+				Stats: []*entities.TweeterStats{
+					&entities.TweeterStats{
+						FullName:    "John Smith",
+						Username:    "jsmith",
+						TweetsCount: 3,
+					},
 				},
+				PagesCount:  1,
+				TweetsCount: 3,
 			}
 
 			oauthClient, err := auth.NewOauth1Client(
 				"consumerKey",
 				"consumerSecret",
 				"callbackURL",
+				auth.TwitterEndpoint(auth.DefaultTwitterURL),
 			)
 
 			if err != nil {
 				t.Fatal(err)
 			}
 
-			tweetsService := services.NewTweetsService(oauthClient)
+			tweetsService := services.NewTweetsService(
+				oauthClient,
+				800,
+				services.DefaultAPIURL,
+			)
 
 			usecase := func(
+				ctx context.Context,
 				service services.TweetsService, accessToken,
 				accessSecret string,
+				window usecases.TimeWindow,
+				query usecases.StatsQuery,
 			) (
-				[]*entities.TweeterStats, error,
+				*usecases.TweetersStatsResult, error,
 			) {
 
 				if service != tweetsService ||
@@ -327,7 +471,7 @@ func TestTweetersStats(t *testing.T) {
 			}
 
 			rr := httptest.NewRecorder()
-			handler := http.HandlerFunc(TweetersStats(usecase, tweetsService))
+			handler := http.HandlerFunc(TweetersStats(usecase, c, tweetsService, store))
 			handler.ServeHTTP(rr, req)
 
 			if status := rr.Code; status != http.StatusOK {
@@ -341,15 +485,125 @@ func TestTweetersStats(t *testing.T) {
 			var responseBody TweetersStatsResponse
 			json.NewDecoder(rr.Body).Decode(&responseBody)
 
-			if !reflect.DeepEqual(responseBody.Data, result) {
+			if !reflect.DeepEqual(responseBody.Data, result.Stats) ||
+				responseBody.PagesCount != result.PagesCount ||
+				responseBody.TweetsCount != result.TweetsCount {
 				t.Errorf(
 					"Incorrect response body: %v, expected: %v",
-					responseBody.Data,
+					responseBody,
 					result,
 				)
 			}
 		})
 
+	t.Run("should support conditional requests", func(t *testing.T) {
+		store, sessionCookie := newSession(t)
+		fetchedAt := time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC)
+
+		usecase := func(
+			ctx context.Context,
+			service services.TweetsService, accessToken,
+			accessSecret string,
+			window usecases.TimeWindow,
+			query usecases.StatsQuery,
+		) (
+			*usecases.TweetersStatsResult, error,
+		) {
+
+			return &usecases.TweetersStatsResult{
+				Stats:     []*entities.TweeterStats{},
+				FetchedAt: fetchedAt,
+			}, nil
+		}
+
+		handler := http.HandlerFunc(TweetersStats(usecase, c, nil, store))
+		serve := func(header, value string) *httptest.ResponseRecorder {
+			req, err := http.NewRequest("GET", "/tweeters-stats", nil)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.AddCookie(sessionCookie)
+			if header != "" {
+				req.Header.Set(header, value)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			return rr
+		}
+
+		//
+		rr := serve("", "")
+		etag := rr.Header().Get("ETag")
+
+		if rr.Code != http.StatusOK ||
+			etag == "" ||
+			rr.Header().Get("Last-Modified") != "Sat, 01 Sep 2018 12:00:00 GMT" {
+			t.Errorf("Should set validators: %v", rr.Header())
+		}
+
+		//
+		if rr := serve("If-None-Match", etag); rr.Code != http.StatusNotModified ||
+			rr.Body.Len() != 0 {
+			t.Errorf("Expected 304 HTTP status code for a matching ETag")
+		}
+
+		if rr := serve("If-None-Match", `"blablabla"`); rr.Code != http.StatusOK {
+			t.Errorf("Expected 200 HTTP status code for a stale ETag")
+		}
+
+		//
+		rr = serve("If-Modified-Since", "Sat, 01 Sep 2018 12:00:00 GMT")
+		if rr.Code != http.StatusNotModified {
+			t.Errorf("Expected 304 HTTP status code when not modified since")
+		}
+
+		rr = serve("If-Modified-Since", "Sat, 01 Sep 2018 11:59:59 GMT")
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected 200 HTTP status code when modified since")
+		}
+	})
+
+	t.Run("should skip the cache with refresh=true", func(t *testing.T) {
+		store, sessionCookie := newSession(t)
+		cachedService := services.NewCachedTweetsService(nil, time.Minute)
+
+		for query, wantCached := range map[string]bool{
+			"":              true,
+			"?refresh=true": false,
+		} {
+			req, err := http.NewRequest("GET", "/tweeters-stats"+query, nil)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.AddCookie(sessionCookie)
+			usecase := func(
+				ctx context.Context,
+				service services.TweetsService, accessToken,
+				accessSecret string,
+				window usecases.TimeWindow,
+				query usecases.StatsQuery,
+			) (
+				*usecases.TweetersStatsResult, error,
+			) {
+
+				if (service == cachedService) != wantCached {
+					t.Errorf("Incorrect service for %#v", query)
+				}
+
+				return &usecases.TweetersStatsResult{}, nil
+			}
+
+			rr := httptest.NewRecorder()
+			handler := TweetersStats(usecase, c, cachedService, store)
+			handler.ServeHTTP(rr, req)
+		}
+	})
+
 	t.Run("should handle the usecase error with a 401 code", func(t *testing.T) {
 		req, err := http.NewRequest("GET", "/tweeters-stats", nil)
 
@@ -361,34 +615,859 @@ func TestTweetersStats(t *testing.T) {
 			"consumerKey",
 			"consumerSecret",
 			"callbackURL",
+			auth.TwitterEndpoint(auth.DefaultTwitterURL),
 		)
 
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		tweetsService := services.NewTweetsService(oauthClient)
+		tweetsService := services.NewTweetsService(
+			oauthClient,
+			800,
+			services.DefaultAPIURL,
+		)
 
 		usecase := func(
+			ctx context.Context,
 			service services.TweetsService, accessToken,
 			accessSecret string,
+			window usecases.TimeWindow,
+			query usecases.StatsQuery,
 		) (
-			[]*entities.TweeterStats, error,
+			*usecases.TweetersStatsResult, error,
 		) {
 
-			return nil, errors.New("whaaat -_-")
+			return nil, &entities.UnauthenticatedError{Reason: "whaaat -_-"}
 		}
 
 		rr := httptest.NewRecorder()
-		handler := http.HandlerFunc(TweetersStats(usecase, tweetsService))
+		handler := http.HandlerFunc(TweetersStats(
+			usecase,
+			c,
+			tweetsService,
+			sessions.NewMemoryStore(time.Hour),
+		))
 		handler.ServeHTTP(rr, req)
 
 		if status := rr.Code; status != http.StatusUnauthorized {
 			t.Errorf("Expected 401 HTTP status code")
 		}
 
+		var responseBody ErrorResponse
+		json.NewDecoder(rr.Body).Decode(&responseBody)
+
+		if responseBody.Error.Code != "unauthenticated" {
+			t.Errorf("Incorrect response body: %v", responseBody)
+		}
+
 		if setCookie := rr.Header().Get("Set-Cookie"); setCookie != "" {
 			t.Errorf("Incorrect Set-Cookie value: %v", setCookie)
 		}
 	})
+	t.Run("should reject an invalid time window with a 400 code", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/tweeters-stats?window=yesterday", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		usecase := func(
+			ctx context.Context,
+			service services.TweetsService, accessToken,
+			accessSecret string,
+			window usecases.TimeWindow,
+			query usecases.StatsQuery,
+		) (
+			*usecases.TweetersStatsResult, error,
+		) {
+
+			t.Errorf("usecase shouldn't be called with an invalid window")
+			return nil, nil
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(TweetersStats(usecase, c, nil, nil))
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected 400 HTTP status code")
+		}
+	})
+}
+
+func TestArchiveTweetersStats(t *testing.T) {
+	store, sessionCookie := newSession(t)
+
+	archiveRequest := func(t *testing.T) *http.Request {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, err := writer.CreateFormFile("archive", "twitter.zip")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		part.Write([]byte("archive"))
+		writer.Close()
+
+		req, err := http.NewRequest("POST", "/tweeters-stats/archive", &body)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.AddCookie(sessionCookie)
+		return req
+	}
+
+	tweetsService := services.NewArchiveTweetsService("twitter.zip")
+	newService := func(r io.ReaderAt, size int64) services.TweetsService {
+		data := make([]byte, size)
+		r.ReadAt(data, 0)
+
+		if string(data) != "archive" {
+			t.Errorf("uploaded archive not passed correctly: %v", string(data))
+		}
+
+		return tweetsService
+	}
+
+	t.Run("should use underlying implementation", func(t *testing.T) {
+		result := &usecases.TweetersStatsResult{
+			Stats: []*entities.TweeterStats{
+				&entities.TweeterStats{
+					FullName:    "John Smith",
+					Username:    "jsmith",
+					TweetsCount: 1,
+				},
+			},
+			PagesCount:  1,
+			TweetsCount: 1,
+		}
+
+		usecase := func(
+			ctx context.Context,
+			service services.TweetsService,
+			window usecases.TimeWindow,
+		) (
+			*usecases.TweetersStatsResult, error,
+		) {
+
+			if service != tweetsService {
+				t.Errorf("service not passed to archive usecase correctly -_-")
+			}
+
+			return result, nil
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(ArchiveTweetersStats(usecase, newService, c, store))
+		handler.ServeHTTP(rr, archiveRequest(t))
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Expected 200 HTTP status code")
+		}
+
+		var responseBody TweetersStatsResponse
+		json.NewDecoder(rr.Body).Decode(&responseBody)
+
+		if !reflect.DeepEqual(responseBody.Data, result.Stats) {
+			t.Errorf("Incorrect response body: %v", responseBody)
+		}
+	})
+
+	t.Run("should handle an invalid archive with a 400 code", func(t *testing.T) {
+		usecase := func(
+			ctx context.Context,
+			service services.TweetsService,
+			window usecases.TimeWindow,
+		) (
+			*usecases.TweetersStatsResult, error,
+		) {
+
+			return nil, &entities.InvalidInputError{Message: "whaaat -_-"}
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(ArchiveTweetersStats(usecase, newService, c, store))
+		handler.ServeHTTP(rr, archiveRequest(t))
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected 400 HTTP status code")
+		}
+	})
+
+	t.Run("should reject a request without an archive", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "/tweeters-stats/archive", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.AddCookie(sessionCookie)
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(ArchiveTweetersStats(nil, newService, c, store))
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected 400 HTTP status code")
+		}
+	})
+
+	t.Run("should reject a request without a session", func(t *testing.T) {
+		req := archiveRequest(t)
+		req.Header.Del("Cookie")
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(ArchiveTweetersStats(nil, newService, c, store))
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusUnauthorized {
+			t.Errorf("Expected 401 HTTP status code: %v", status)
+		}
+	})
+
+	t.Run("should reject a too large archive", func(t *testing.T) {
+		defer func(max int64) { maxArchiveUpload = max }(maxArchiveUpload)
+		maxArchiveUpload = 16
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(ArchiveTweetersStats(nil, newService, c, store))
+		handler.ServeHTTP(rr, archiveRequest(t))
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected 400 HTTP status code: %v", status)
+		}
+	})
+}
+
+func TestAmplifiedStats(t *testing.T) {
+	t.Run("should use underlying implementation", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/tweeters-stats/amplified", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store, sessionCookie := newSession(t)
+		req.AddCookie(sessionCookie)
+
+		result := &usecases.AmplifiedStatsResult{
+			Stats: []*entities.AmplifiedStats{
+				&entities.AmplifiedStats{
+					FullName:        "Jane Doe",
+					Username:        "jdoe",
+					RetweetsCount:   3,
+					RetweetersCount: 2,
+				},
+			},
+			PagesCount:  1,
+			TweetsCount: 5,
+		}
+
+		usecase := func(
+			ctx context.Context,
+			service services.TweetsService, accessToken,
+			accessSecret string,
+			window usecases.TimeWindow,
+		) (
+			*usecases.AmplifiedStatsResult, error,
+		) {
+
+			if accessToken != "accessToken" || accessSecret != "accessSecret" {
+				t.Errorf("parameters not passed to amplified usecase correctly -_-")
+			}
+
+			return result, nil
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(AmplifiedStats(usecase, c, nil, store))
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Expected 200 HTTP status code")
+		}
+
+		var responseBody AmplifiedStatsResponse
+		json.NewDecoder(rr.Body).Decode(&responseBody)
+
+		if !reflect.DeepEqual(responseBody.Data, result.Stats) ||
+			responseBody.TweetsCount != result.TweetsCount {
+			t.Errorf("Incorrect response body: %v", responseBody)
+		}
+	})
+
+	t.Run("should handle the usecase error with a 401 code", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/tweeters-stats/amplified", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		usecase := func(
+			ctx context.Context,
+			service services.TweetsService, accessToken,
+			accessSecret string,
+			window usecases.TimeWindow,
+		) (
+			*usecases.AmplifiedStatsResult, error,
+		) {
+
+			return nil, &entities.UnauthenticatedError{Reason: "whaaat -_-"}
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(AmplifiedStats(
+			usecase,
+			c,
+			nil,
+			sessions.NewMemoryStore(time.Hour),
+		))
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusUnauthorized {
+			t.Errorf("Expected 401 HTTP status code")
+		}
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Run("should return the rate limit of the session", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/tweeters-stats/rate-limit", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store, sessionCookie := newSession(t)
+		req.AddCookie(sessionCookie)
+
+		rateLimit := &entities.RateLimit{
+			Limit:     15,
+			Remaining: 3,
+			Reset:     time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC),
+		}
+
+		usecase := func(
+			service services.TweetsService,
+			accessToken,
+			accessSecret string,
+		) (*entities.RateLimit, error) {
+
+			if accessToken != "accessToken" || accessSecret != "accessSecret" {
+				t.Errorf("parameters not passed to usecase correctly -_-")
+			}
+
+			return rateLimit, nil
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(RateLimit(usecase, c, nil, store))
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Expected 200 HTTP status code")
+		}
+
+		var responseBody RateLimitResponse
+		json.NewDecoder(rr.Body).Decode(&responseBody)
+
+		if responseBody.Data == nil || *responseBody.Data != *rateLimit {
+			t.Errorf("Incorrect response body: %v", responseBody)
+		}
+	})
+
+	t.Run("should handle the usecase error with a 401 code", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/tweeters-stats/rate-limit", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		usecase := func(
+			service services.TweetsService,
+			accessToken,
+			accessSecret string,
+		) (*entities.RateLimit, error) {
+
+			return nil, &entities.UnauthenticatedError{Reason: "whaaat -_-"}
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(RateLimit(
+			usecase,
+			c,
+			nil,
+			sessions.NewMemoryStore(time.Hour),
+		))
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusUnauthorized {
+			t.Errorf("Expected 401 HTTP status code")
+		}
+	})
+}
+
+func Test_timeWindow(t *testing.T) {
+	now := time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		query   url.Values
+		want    usecases.TimeWindow
+		wantErr bool
+	}{
+		{
+			name:  "should return an empty window by default",
+			query: url.Values{},
+			want:  usecases.TimeWindow{},
+		},
+		{
+			name:  "should support presets",
+			query: url.Values{"window": {"day"}},
+			want:  usecases.TimeWindow{Since: now.Add(-24 * time.Hour)},
+		},
+		{
+			name:  "should support durations",
+			query: url.Values{"window": {"90m"}},
+			want:  usecases.TimeWindow{Since: now.Add(-90 * time.Minute)},
+		},
+		{
+			name: "should support since and until",
+			query: url.Values{
+				"since": {"2018-08-01T00:00:00Z"},
+				"until": {"2018-08-02T00:00:00Z"},
+			},
+			want: usecases.TimeWindow{
+				Since: time.Date(2018, 8, 1, 0, 0, 0, 0, time.UTC),
+				Until: time.Date(2018, 8, 2, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:    "should reject combining window and since",
+			query:   url.Values{"window": {"day"}, "since": {"2018-08-01T00:00:00Z"}},
+			wantErr: true,
+		},
+		{
+			name: "should reject since after until",
+			query: url.Values{
+				"since": {"2018-08-02T00:00:00Z"},
+				"until": {"2018-08-01T00:00:00Z"},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "should reject a negative window",
+			query:   url.Values{"window": {"-1h"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := timeWindow(tt.query, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("timeWindow() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("timeWindow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeSeries(t *testing.T) {
+	t.Run("should use underlying implementation", func(t *testing.T) {
+		req, err := http.NewRequest(
+			"GET",
+			"/tweeters-stats/timeseries?bucket=hour&username=jdoe",
+			nil,
+		)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store, sessionCookie := newSession(t)
+		req.AddCookie(sessionCookie)
+
+		point := entities.TimeSeriesPoint{
+			Time:  time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC),
+			Count: 2,
+		}
+		result := &usecases.TimeSeriesResult{
+			Series: []*entities.TweeterTimeSeries{
+				&entities.TweeterTimeSeries{
+					FullName:    "Jane Doe",
+					Username:    "jdoe",
+					TweetsCount: 2,
+					Points:      []entities.TimeSeriesPoint{point},
+				},
+			},
+			Total:       []entities.TimeSeriesPoint{point},
+			PagesCount:  1,
+			TweetsCount: 2,
+		}
+
+		usecase := func(
+			ctx context.Context,
+			service services.TweetsService,
+			accessToken,
+			accessSecret string,
+			window usecases.TimeWindow,
+			query usecases.TimeSeriesQuery,
+		) (
+			*usecases.TimeSeriesResult, error,
+		) {
+
+			if accessToken != "accessToken" ||
+				accessSecret != "accessSecret" ||
+				query.Bucket != usecases.HourBucket ||
+				query.Username != "jdoe" {
+				t.Errorf("parameters not passed to timeseries usecase correctly -_-")
+			}
+
+			return result, nil
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(TimeSeries(usecase, c, nil, store))
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Expected 200 HTTP status code")
+		}
+
+		var responseBody TimeSeriesResponse
+		json.NewDecoder(rr.Body).Decode(&responseBody)
+
+		if !reflect.DeepEqual(responseBody.Data, result.Series) ||
+			!reflect.DeepEqual(responseBody.Total, result.Total) ||
+			responseBody.Bucket != usecases.HourBucket ||
+			responseBody.TweetsCount != result.TweetsCount {
+			t.Errorf("Incorrect response body: %v", responseBody)
+		}
+	})
+
+	t.Run("should reject invalid buckets with a 400 code", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/tweeters-stats/timeseries?bucket=week", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		usecase := func(
+			ctx context.Context,
+			service services.TweetsService,
+			accessToken,
+			accessSecret string,
+			window usecases.TimeWindow,
+			query usecases.TimeSeriesQuery,
+		) (
+			*usecases.TimeSeriesResult, error,
+		) {
+
+			t.Errorf("Should not call the usecase")
+			return nil, nil
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(TimeSeries(
+			usecase,
+			c,
+			nil,
+			sessions.NewMemoryStore(time.Hour),
+		))
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected 400 HTTP status code: %v", status)
+		}
+	})
+}
+
+func TestHeatmap(t *testing.T) {
+	t.Run("should use underlying implementation", func(t *testing.T) {
+		req, err := http.NewRequest(
+			"GET",
+			"/tweeters-stats/heatmap?timezone=Asia/Riyadh",
+			nil,
+		)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store, sessionCookie := newSession(t)
+		req.AddCookie(sessionCookie)
+
+		result := &usecases.HeatmapResult{
+			Heatmaps: []*entities.TweeterHeatmap{
+				&entities.TweeterHeatmap{Username: "jdoe", TweetsCount: 1},
+			},
+			PagesCount:  1,
+			TweetsCount: 1,
+		}
+		result.Heatmaps[0].Heatmap[6][23] = 1
+		result.Total[6][23] = 1
+
+		usecase := func(
+			ctx context.Context,
+			service services.TweetsService,
+			accessToken,
+			accessSecret string,
+			window usecases.TimeWindow,
+			location *time.Location,
+		) (
+			*usecases.HeatmapResult, error,
+		) {
+
+			if accessToken != "accessToken" ||
+				accessSecret != "accessSecret" ||
+				location.String() != "Asia/Riyadh" {
+				t.Errorf("parameters not passed to heatmap usecase correctly -_-")
+			}
+
+			return result, nil
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Heatmap(usecase, c, nil, store))
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Expected 200 HTTP status code")
+		}
+
+		var responseBody HeatmapResponse
+		json.NewDecoder(rr.Body).Decode(&responseBody)
+
+		if !reflect.DeepEqual(responseBody.Data, result.Heatmaps) ||
+			responseBody.Total != result.Total ||
+			responseBody.Timezone != "Asia/Riyadh" {
+			t.Errorf("Incorrect response body: %v", responseBody)
+		}
+	})
+
+	t.Run("should reject invalid timezones with a 400 code", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/tweeters-stats/heatmap?timezone=Mars/Olympus", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		usecase := func(
+			ctx context.Context,
+			service services.TweetsService,
+			accessToken,
+			accessSecret string,
+			window usecases.TimeWindow,
+			location *time.Location,
+		) (
+			*usecases.HeatmapResult, error,
+		) {
+
+			t.Errorf("Should not call the usecase")
+			return nil, nil
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Heatmap(
+			usecase,
+			c,
+			nil,
+			sessions.NewMemoryStore(time.Hour),
+		))
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected 400 HTTP status code: %v", status)
+		}
+	})
+}
+
+func TestEntityStats(t *testing.T) {
+	t.Run("should use underlying implementation", func(t *testing.T) {
+		req, err := http.NewRequest(
+			"GET",
+			"/tweeters-stats/hashtags?sort=username&order=desc&limit=10&offset=5",
+			nil,
+		)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store, sessionCookie := newSession(t)
+		req.AddCookie(sessionCookie)
+
+		result := &usecases.EntityStatsResult{
+			Stats: []*entities.EntityStats{
+				&entities.EntityStats{Name: "golang", TweetsCount: 3, TweetersCount: 2},
+			},
+			PagesCount:  1,
+			TweetsCount: 5,
+		}
+
+		usecase := func(
+			ctx context.Context,
+			service services.TweetsService,
+			accessToken,
+			accessSecret string,
+			window usecases.TimeWindow,
+			query usecases.StatsQuery,
+		) (
+			*usecases.EntityStatsResult, error,
+		) {
+
+			if accessToken != "accessToken" ||
+				accessSecret != "accessSecret" ||
+				query != (usecases.StatsQuery{
+					SortBy: usecases.SortByUsername,
+					Limit:  10,
+					Offset: 5,
+				}) {
+				t.Errorf("parameters not passed to entity stats usecase correctly -_-")
+			}
+
+			return result, nil
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(EntityStats(usecase, c, nil, store))
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("Expected 200 HTTP status code")
+		}
+
+		var responseBody EntityStatsResponse
+		json.NewDecoder(rr.Body).Decode(&responseBody)
+
+		if !reflect.DeepEqual(responseBody.Data, result.Stats) ||
+			responseBody.TweetsCount != result.TweetsCount {
+			t.Errorf("Incorrect response body: %v", responseBody)
+		}
+	})
+
+	t.Run("should reject invalid queries with a 400 code", func(t *testing.T) {
+		for _, query := range []string{"limit=-1", "limit=ten", "offset=-1", "sort=name"} {
+			req, err := http.NewRequest("GET", "/tweeters-stats/hashtags?"+query, nil)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			handler := http.HandlerFunc(EntityStats(
+				usecases.HashtagsStats,
+				c,
+				nil,
+				sessions.NewMemoryStore(time.Hour),
+			))
+			handler.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != http.StatusBadRequest {
+				t.Errorf("Expected 400 HTTP status code for %v: %v", query, status)
+			}
+		}
+	})
+}
+
+func TestTweetersStats_query(t *testing.T) {
+	store, sessionCookie := newSession(t)
+
+	usecase := func(
+		ctx context.Context,
+		service services.TweetsService, accessToken,
+		accessSecret string,
+		window usecases.TimeWindow,
+		query usecases.StatsQuery,
+	) (
+		*usecases.TweetersStatsResult, error,
+	) {
+
+		want := usecases.StatsQuery{
+			SortBy:    usecases.SortByUsername,
+			Ascending: false,
+			Limit:     10,
+			Offset:    20,
+		}
+
+		if query != want {
+			t.Errorf("query not passed to tweeters-stats usecase correctly: %v", query)
+		}
+
+		return &usecases.TweetersStatsResult{
+			Stats:      []*entities.TweeterStats{},
+			TotalCount: 42,
+		}, nil
+	}
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", target, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.AddCookie(sessionCookie)
+
+		rr := httptest.NewRecorder()
+		TweetersStats(usecase, c, nil, store).ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	//
+	rr := serve("/tweeters-stats?sort=username&order=desc&limit=10&offset=20")
+	var responseBody TweetersStatsResponse
+	json.NewDecoder(rr.Body).Decode(&responseBody)
+
+	if rr.Code != http.StatusOK || responseBody.TotalCount != 42 {
+		t.Errorf("Should return the total count: %v, %v", rr.Code, responseBody)
+	}
+
+	//
+	for _, target := range []string{
+		"/tweeters-stats?sort=followers",
+		"/tweeters-stats?order=up",
+		"/tweeters-stats?offset=-1",
+	} {
+		if rr := serve(target); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 HTTP status code for %v: %v", target, rr.Code)
+		}
+	}
+}
+
+func Test_requestContext(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/tweeters-stats", nil).WithContext(parent)
+
+	//
+	ctx, cancelCtx := requestContext(&config.Config{RequestTimeout: time.Minute}, req)
+	defer cancelCtx()
+
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("Should set a deadline of RequestTimeout: %v", deadline)
+	}
+
+	//
+	noDeadline, cancelNoDeadline := requestContext(&config.Config{}, req)
+	defer cancelNoDeadline()
+
+	if _, ok := noDeadline.Deadline(); ok {
+		t.Errorf("Should not set a deadline when RequestTimeout is zero")
+	}
+
+	//
+	cancel()
+
+	if ctx.Err() != context.Canceled || noDeadline.Err() != context.Canceled {
+		t.Errorf("Should be cancelled when the client goes away")
+	}
 }
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/config"
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/Ahimta/tweeters-stats-golang/services"
+	"github.com/Ahimta/tweeters-stats-golang/sessions"
+	"github.com/Ahimta/tweeters-stats-golang/usecases"
+)
+
+type streamTweetersStatsUsecaseFunc func(
+	ctx context.Context,
+	tweetsService services.TweetsService,
+	accessToken,
+	accessSecret string,
+	window usecases.TimeWindow,
+	query usecases.StatsQuery,
+	progress func(partial *usecases.TweetersStatsResult),
+) (
+	*usecases.TweetersStatsResult, error,
+)
+
+// TweetersStatsStream is TweetersStats as Server-Sent Events, a progress
+// event (with the stats so far) per fetched timeline page followed by either
+// a result or an error event, clients should close the stream after either
+// (EventSource reconnects otherwise)
+func TweetersStatsStream(
+	usecase streamTweetersStatsUsecaseFunc,
+	c *config.Config,
+	service services.TweetsService,
+	store sessions.Store) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeMethodNotAllowed(w, r)
+			return
+		}
+
+		window, err := timeWindow(r.URL.Query(), time.Now())
+
+		if err != nil {
+			writeError(w, r, &entities.InvalidInputError{Message: err.Error()})
+			return
+		}
+
+		query, err := statsQuery(r.URL.Query())
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+
+		if !ok {
+			writeError(w, r, errors.New("handlers: streaming isn't supported"))
+			return
+		}
+
+		tweetsService := service
+		if r.URL.Query().Get("refresh") == "true" {
+			tweetsService = services.Refreshing(service)
+		}
+
+		header := w.Header()
+		header.Set("Content-Type", "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+
+		// nginx buffers responses (and so events) by default
+		header.Set("X-Accel-Buffering", "no")
+
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		send := func(event string, value interface{}) {
+			writeEvent(w, event, value)
+			flusher.Flush()
+		}
+
+		ctx, cancel := requestContext(c, r)
+		defer cancel()
+
+		accessToken, accessSecret := sessionTokens(c, r, store)
+		result, err := usecase(
+			ctx,
+			tweetsService,
+			accessToken,
+			accessSecret,
+			window,
+			query,
+			func(partial *usecases.TweetersStatsResult) {
+				send("progress", tweetersStatsResponse(partial))
+			},
+		)
+
+		if err != nil {
+			_, body := errorBody(w, r, err)
+			send("error", &ErrorResponse{Error: body})
+			return
+		}
+
+		send("result", tweetersStatsResponse(result))
+	}
+}
+
+func tweetersStatsResponse(
+	result *usecases.TweetersStatsResult,
+) *TweetersStatsResponse {
+
+	return &TweetersStatsResponse{
+		Data:        result.Stats,
+		TotalCount:  result.TotalCount,
+		Summary:     result.Summary,
+		PagesCount:  result.PagesCount,
+		TweetsCount: result.TweetsCount,
+		RateLimit:   result.RateLimit,
+		Truncated:   result.Truncated,
+	}
+}
+
+// writeEvent writes value as a JSON event, json.Marshal never outputs new
+// lines so it always fits in a single data field
+func writeEvent(w http.ResponseWriter, event string, value interface{}) error {
+	data, err := json.Marshal(value)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/Ahimta/tweeters-stats-golang/middleware"
+	"github.com/Ahimta/tweeters-stats-golang/services"
+	"github.com/Ahimta/tweeters-stats-golang/usecases"
+)
+
+func TestTweetersStatsStream(t *testing.T) {
+	store, sessionCookie := newSession(t)
+
+	serve := func(
+		target string,
+		err error,
+	) *httptest.ResponseRecorder {
+
+		usecase := func(
+			ctx context.Context,
+			service services.TweetsService,
+			accessToken,
+			accessSecret string,
+			window usecases.TimeWindow,
+			query usecases.StatsQuery,
+			progress func(partial *usecases.TweetersStatsResult),
+		) (
+			*usecases.TweetersStatsResult, error,
+		) {
+
+			if accessToken != "accessToken" || query.Limit != 10 {
+				t.Errorf("parameters not passed to stream usecase correctly -_-")
+			}
+
+			for pagesCount := uint(1); pagesCount <= 2; pagesCount++ {
+				progress(&usecases.TweetersStatsResult{
+					Stats:      []*entities.TweeterStats{},
+					PagesCount: pagesCount,
+					RateLimit:  &entities.RateLimit{Remaining: 15 - int(pagesCount)},
+				})
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			return &usecases.TweetersStatsResult{
+				Stats:      []*entities.TweeterStats{{Username: "jsmith"}},
+				PagesCount: 2,
+			}, nil
+		}
+
+		req := httptest.NewRequest("GET", target, nil)
+		req.AddCookie(sessionCookie)
+
+		rr := httptest.NewRecorder()
+		TweetersStatsStream(usecase, c, nil, store).ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	type event struct{ name, data string }
+
+	parse := func(body string) []event {
+		events := []event{}
+
+		for _, block := range strings.Split(strings.TrimSpace(body), "\n\n") {
+			lines := strings.Split(block, "\n")
+
+			if len(lines) != 2 {
+				t.Fatalf("Incorrect event: %q", block)
+			}
+
+			events = append(events, event{
+				strings.TrimPrefix(lines[0], "event: "),
+				strings.TrimPrefix(lines[1], "data: "),
+			})
+		}
+
+		return events
+	}
+
+	//
+	rr := serve("/tweeters-stats/stream?limit=10", nil)
+	events := parse(rr.Body.String())
+
+	if rr.Code != http.StatusOK ||
+		rr.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Incorrect stream headers: %v, %v", rr.Code, rr.Header())
+	}
+
+	if len(events) != 3 ||
+		events[0].name != "progress" ||
+		events[1].name != "progress" ||
+		events[2].name != "result" {
+		t.Fatalf("Should send progress events then the result: %v", events)
+	}
+
+	var progress TweetersStatsResponse
+	json.Unmarshal([]byte(events[1].data), &progress)
+
+	if progress.PagesCount != 2 || progress.RateLimit.Remaining != 13 {
+		t.Errorf("Incorrect progress event: %v", events[1].data)
+	}
+
+	var result TweetersStatsResponse
+	json.Unmarshal([]byte(events[2].data), &result)
+
+	if len(result.Data) != 1 || result.Data[0].Username != "jsmith" {
+		t.Errorf("Incorrect result event: %v", events[2].data)
+	}
+
+	//
+	rr = serve(
+		"/tweeters-stats/stream?limit=10",
+		&entities.UnauthenticatedError{Reason: "blablabla"},
+	)
+	events = parse(rr.Body.String())
+
+	var errorResponse ErrorResponse
+	json.Unmarshal([]byte(events[len(events)-1].data), &errorResponse)
+
+	if events[len(events)-1].name != "error" ||
+		errorResponse.Error.Code != "unauthenticated" {
+		t.Errorf("Should end the stream with an error event: %v", events)
+	}
+
+	//
+	if rr := serve("/tweeters-stats/stream?sort=followers", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 HTTP status code: %v", rr.Code)
+	}
+}
+
+func TestTweetersStatsStream_middleware(t *testing.T) {
+	store, sessionCookie := newSession(t)
+
+	usecase := func(
+		ctx context.Context,
+		service services.TweetsService,
+		accessToken,
+		accessSecret string,
+		window usecases.TimeWindow,
+		query usecases.StatsQuery,
+		progress func(partial *usecases.TweetersStatsResult),
+	) (
+		*usecases.TweetersStatsResult, error,
+	) {
+
+		progress(&usecases.TweetersStatsResult{PagesCount: 1})
+		return &usecases.TweetersStatsResult{PagesCount: 1}, nil
+	}
+
+	handler := middleware.Apply(
+		TweetersStatsStream(usecase, c, nil, store),
+		ioutil.Discard,
+		c,
+	)
+
+	// like EventSource, i.e., without X-Requested-With
+	serve := func(referer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "http://localhost/tweeters-stats/stream", nil)
+		req.Header.Set("Referer", referer)
+		req.AddCookie(sessionCookie)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	//
+	rr := serve("http://localhost/")
+
+	if rr.Code != http.StatusOK ||
+		rr.Header().Get("Content-Type") != "text/event-stream" ||
+		!rr.Flushed ||
+		!strings.Contains(rr.Body.String(), "event: result\n") {
+
+		t.Errorf("Should stream through the middleware: %v, %q", rr.Code, rr.Body.String())
+	}
+
+	//
+	if rr := serve("http://evil.com/"); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 HTTP status code: %v", rr.Code)
+	}
+}
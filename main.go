@@ -1,34 +1,57 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/Ahimta/tweeters-stats-golang/auth"
+	"github.com/Ahimta/tweeters-stats-golang/collector"
 	"github.com/Ahimta/tweeters-stats-golang/config"
 	"github.com/Ahimta/tweeters-stats-golang/handlers"
 	"github.com/Ahimta/tweeters-stats-golang/middleware"
 	"github.com/Ahimta/tweeters-stats-golang/services"
+	"github.com/Ahimta/tweeters-stats-golang/sessions"
+	"github.com/Ahimta/tweeters-stats-golang/storage"
 	"github.com/Ahimta/tweeters-stats-golang/usecases"
 	newrelic "github.com/newrelic/go-agent"
 )
 
+// how long New Relic has to send its remaining data on shutdown
+const newRelicFlushTimeout = 10 * time.Second
+
 func main() {
-	c, err := config.New(
-		os.Getenv("CONSUMER_KEY"),
-		os.Getenv("CONSUMER_SECRET"),
-		os.Getenv("CALLBACK_URL"),
-		os.Getenv("PORT"),
-		os.Getenv("HOMEPAGE"),
-		os.Getenv("HOST"),
-		os.Getenv("PROTOCOL"),
-		os.Getenv("CORS_DOMAIN"),
+	archivePath := flag.String(
+		"archive",
+		"",
+		"print tweeters stats of a Twitter archive (ZIP or directory) and exit",
 	)
+	since := flag.String("since", "", "only count archive tweets since (RFC 3339)")
+	until := flag.String("until", "", "only count archive tweets until (RFC 3339)")
+	flag.Parse()
+
+	if *archivePath != "" {
+		os.Exit(printArchiveStats(*archivePath, *since, *until))
+	}
+
+	os.Exit(serve())
+}
+
+// serve runs the server until SIGINT or SIGTERM and returns the exit code, it
+// doesn't exit itself so deferred cleanups run
+func serve() int {
+	c, err := config.New(os.Getenv)
 
 	if err != nil {
 		fmt.Println(err.Error())
-		os.Exit(1)
+		return 1
 	}
 
 	var app newrelic.Application
@@ -38,27 +61,104 @@ func main() {
 
 		if err != nil {
 			fmt.Println(err.Error())
-			os.Exit(2)
+			return 2
 		}
+
+		// flushes data not yet sent to New Relic
+		defer app.Shutdown(newRelicFlushTimeout)
 	}
 
 	oauthClient, err := auth.NewOauth1Client(
 		c.ConsumerKey,
 		c.ConsumerSecret,
 		c.CallbackURL,
+		auth.Endpoint{
+			RequestTokenURL: c.RequestTokenURL,
+			AuthorizeURL:    c.AuthorizeURL,
+			AccessTokenURL:  c.AccessTokenURL,
+		},
 	)
 
 	if err != nil {
 		fmt.Println(err.Error())
-		os.Exit(1)
+		return 1
+	}
+
+	var store sessions.Store
+	if c.SessionStorePath == "" {
+		store = sessions.NewMemoryStore(c.SessionTTL)
+	} else {
+		boltStore, err := sessions.NewBoltStore(c.SessionStorePath, c.SessionTTL)
+
+		if err != nil {
+			fmt.Println(err.Error())
+			return 1
+		}
+
+		defer boltStore.Close()
+		store = boltStore
+	}
+
+	requestTokens := sessions.NewRequestTokenStore(usecases.LoginTTL)
+
+	twitterService := services.NewTweetsService(
+		oauthClient,
+		c.TimelineBudget,
+		c.APIURL,
+	)
+
+	var tweetsService services.TweetsService = twitterService
+	var col *collector.Collector
+
+	if c.HistoryStorePath != "" {
+		history, err := storage.NewBoltStore(
+			c.HistoryStorePath,
+			append([][]byte{c.CookieKey}, c.CookieOldKeys...),
+		)
+
+		if err != nil {
+			fmt.Println(err.Error())
+			return 1
+		}
+
+		defer history.Close()
+		tweetsService = services.NewHistoryTweetsService(tweetsService, history)
+
+		if c.CollectorInterval > 0 {
+			col, err = collector.New(
+				twitterService,
+				history,
+				history,
+				c.CollectorInterval,
+			)
+
+			if err != nil {
+				fmt.Println(err.Error())
+				return 1
+			}
+
+			col.Start()
+			defer col.Stop()
+		}
+	}
+
+	if c.StatsCacheTTL > 0 {
+		tweetsService = services.NewCachedTweetsService(
+			tweetsService,
+			c.StatsCacheTTL,
+		)
 	}
 
-	tweetsService := services.NewTweetsService(oauthClient)
 	mux := http.NewServeMux()
 
 	route(mux, app, "/health-check", handlers.HealthCheck())
 	route(mux, app, "/", handlers.Homepage("index.html"))
-	route(mux, app, "/login/twitter", handlers.Login(usecases.Login, oauthClient))
+	route(
+		mux,
+		app,
+		"/login/twitter",
+		handlers.Login(usecases.Login, c, oauthClient, requestTokens),
+	)
 	route(
 		mux,
 		app,
@@ -67,24 +167,160 @@ func main() {
 			usecases.Oauth1Callback,
 			c,
 			oauthClient,
+			requestTokens,
+			store,
+		),
+	)
+	route(mux, app, "/logout", handlers.Logout(c, store, col))
+	route(
+		mux,
+		app,
+		"/tweeters-stats/archive",
+		handlers.ArchiveTweetersStats(
+			usecases.ArchiveTweetersStats,
+			services.NewZipArchiveTweetsService,
+			c,
+			store,
 		),
 	)
-	route(mux, app, "/logout", handlers.Logout())
 	route(
 		mux,
 		app,
 		"/tweeters-stats",
 		handlers.TweetersStats(
 			usecases.TweetersStats,
+			c,
+			tweetsService,
+			store,
+		),
+	)
+	// not wrapped with NewRelic since its response writer doesn't necessarily
+	// implement http.Flusher, which streaming needs
+	route(
+		mux,
+		nil,
+		"/tweeters-stats/stream",
+		handlers.TweetersStatsStream(
+			usecases.StreamTweetersStats,
+			c,
+			tweetsService,
+			store,
+		),
+	)
+	route(
+		mux,
+		app,
+		"/tweeters-stats/amplified",
+		handlers.AmplifiedStats(
+			usecases.AmplifiedStats,
+			c,
 			tweetsService,
+			store,
 		),
 	)
 
-	fmt.Printf("Server running on %s://%s\n", c.Protocol, c.Host)
-	http.ListenAndServe(
-		fmt.Sprintf(":%s", c.Port),
-		middleware.Apply(mux, os.Stdout, c),
+	route(
+		mux,
+		app,
+		"/tweeters-stats/timeseries",
+		handlers.TimeSeries(usecases.TimeSeries, c, tweetsService, store),
+	)
+	route(
+		mux,
+		app,
+		"/tweeters-stats/heatmap",
+		handlers.Heatmap(usecases.Heatmap, c, tweetsService, store),
 	)
+	route(
+		mux,
+		app,
+		"/tweeters-stats/hashtags",
+		handlers.EntityStats(usecases.HashtagsStats, c, tweetsService, store),
+	)
+	route(
+		mux,
+		app,
+		"/tweeters-stats/mentions",
+		handlers.EntityStats(usecases.MentionsStats, c, tweetsService, store),
+	)
+	route(
+		mux,
+		app,
+		"/tweeters-stats/domains",
+		handlers.EntityStats(usecases.DomainsStats, c, tweetsService, store),
+	)
+	route(
+		mux,
+		app,
+		"/tweeters-stats/graph",
+		handlers.InteractionGraph(
+			usecases.InteractionGraph,
+			c,
+			tweetsService,
+			store,
+		),
+	)
+	route(
+		mux,
+		app,
+		"/tweeters-stats/rate-limit",
+		handlers.RateLimit(usecases.RateLimit, c, tweetsService, store),
+	)
+
+	if col != nil {
+		route(mux, app, "/collection", handlers.Collection(c, col, store))
+		route(mux, app, "/admin/collector", handlers.AdminCollector(c, col))
+	}
+
+	server := &http.Server{
+		Addr:         fmt.Sprintf(":%s", c.Port),
+		Handler:      middleware.Apply(mux, os.Stdout, c),
+		ReadTimeout:  c.ReadTimeout,
+		WriteTimeout: c.WriteTimeout,
+		IdleTimeout:  c.IdleTimeout,
+	}
+
+	listener, err := net.Listen("tcp", server.Addr)
+
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	serveErrors := make(chan error, 1)
+	go func() {
+		serveErrors <- server.Serve(listener)
+	}()
+
+	fmt.Printf("Server running on %s://%s\n", c.Protocol, c.Host)
+
+	select {
+	case err := <-serveErrors:
+		fmt.Println(err.Error())
+		return 1
+	case s := <-signals:
+		fmt.Printf("Received %s, shutting down\n", s)
+	}
+
+	ctx := context.Background()
+	if c.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.ShutdownTimeout)
+		defer cancel()
+	}
+
+	// the collector, stores and New Relic are stopped by deferred calls, so
+	// only once in-flight requests are done with them
+	if err := server.Shutdown(ctx); err != nil {
+		fmt.Println(err.Error())
+		server.Close()
+		return 1
+	}
+
+	return 0
 }
 
 func route(
@@ -100,3 +336,48 @@ func route(
 
 	mux.HandleFunc(newrelic.WrapHandleFunc(app, path, handler))
 }
+
+func printArchiveStats(archivePath, since, until string) int {
+	window := usecases.TimeWindow{}
+
+	for _, bound := range []struct {
+		value string
+		t     *time.Time
+	}{{since, &window.Since}, {until, &window.Until}} {
+		if bound.value == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, bound.value)
+
+		if err != nil {
+			fmt.Println(err.Error())
+			return 1
+		}
+
+		*bound.t = t
+	}
+
+	result, err := usecases.ArchiveTweetersStats(
+		context.Background(),
+		services.NewArchiveTweetsService(archivePath),
+		window,
+	)
+
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(&handlers.TweetersStatsResponse{
+		Data:        result.Stats,
+		TotalCount:  result.TotalCount,
+		Summary:     result.Summary,
+		PagesCount:  result.PagesCount,
+		TweetsCount: result.TweetsCount,
+	})
+
+	return 0
+}
@@ -128,7 +128,13 @@ func Apply(
 			path == "/login/twitter" ||
 			path == "/oauth/twitter/callback") {
 
-			if r.Header.Get("X-Requested-With") != "XMLHttpRequest" ||
+			// EventSource can't set headers, the stream is a GET without side
+			// effects so checking Origin or Referer is enough for it
+			eventStream := path == "/tweeters-stats/stream" &&
+				r.Method == http.MethodGet
+
+			if (!eventStream &&
+				r.Header.Get("X-Requested-With") != "XMLHttpRequest") ||
 				r.Host != host {
 				w.WriteHeader(http.StatusForbidden)
 				return
@@ -165,3 +171,10 @@ func (w *responseWriter) WriteHeader(status int) {
 	w.status = status
 	w.ResponseWriter.WriteHeader(status)
 }
+
+// Flush lets handlers stream (e.g., Server-Sent Events) through the middleware
+func (w *responseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,79 @@
+// Package seal encrypts small values (e.g., cookies and stored tokens) with
+// AES-GCM, values sealed with old keys can still be opened so keys can be
+// rotated
+package seal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+)
+
+// ErrUnknownKey is returned when none of the keys opens a sealed value (e.g.,
+// it was tampered with or sealed with a dropped key)
+var ErrUnknownKey = errors.New("seal: sealed with an unknown key")
+
+// CheckKey returns an error unless key is an AES key (16, 24 or 32 bytes)
+func CheckKey(key []byte) error {
+	_, err := newAEAD(key)
+	return err
+}
+
+// Seal encrypts plaintext with key, additionalData (e.g., a cookie name) is
+// authenticated too so the sealed value can't be used in another context
+func Seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Open tries every key in turn, keyIndex is the one that opened sealed so
+// callers can tell values sealed with an old key (i.e., not keys[0]) apart
+func Open(keys [][]byte, sealed, additionalData []byte) (
+	plaintext []byte, keyIndex int, err error,
+) {
+
+	for i, key := range keys {
+		aead, err := newAEAD(key)
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if len(sealed) < aead.NonceSize() {
+			return nil, 0, errors.New("seal: malformed sealed value")
+		}
+
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+
+		if err != nil {
+			continue
+		}
+
+		return plaintext, i, nil
+	}
+
+	return nil, 0, ErrUnknownKey
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,100 @@
+package seal
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	key := []byte("kkkkkkkkkkkkkkkkkkkkkkkkkkkkkkkk")
+	oldKey := []byte("oooooooooooooooo")
+
+	sealWith := func(key []byte, additionalData string) []byte {
+		sealed, err := Seal(key, []byte("blablabla"), []byte(additionalData))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return sealed
+	}
+
+	tests := []struct {
+		name           string
+		keys           [][]byte
+		sealed         []byte
+		additionalData string
+		wantKeyIndex   int
+		wantErr        bool
+	}{
+		{
+			name:           "should open a value sealed with the current key",
+			keys:           [][]byte{key, oldKey},
+			sealed:         sealWith(key, "session"),
+			additionalData: "session",
+			wantKeyIndex:   0,
+		},
+		{
+			name:           "should open a value sealed with an old key",
+			keys:           [][]byte{key, oldKey},
+			sealed:         sealWith(oldKey, "session"),
+			additionalData: "session",
+			wantKeyIndex:   1,
+		},
+		{
+			name:           "should reject a value sealed with an unknown key",
+			keys:           [][]byte{key},
+			sealed:         sealWith(oldKey, "session"),
+			additionalData: "session",
+			wantErr:        true,
+		},
+		{
+			name:           "should reject a value sealed for another context",
+			keys:           [][]byte{key},
+			sealed:         sealWith(key, "session"),
+			additionalData: "oauthRequestToken",
+			wantErr:        true,
+		},
+		{
+			name:           "should reject a malformed value",
+			keys:           [][]byte{key},
+			sealed:         []byte("blabla"),
+			additionalData: "session",
+			wantErr:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext, keyIndex, err := Open(tt.keys, tt.sealed, []byte(tt.additionalData))
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !bytes.Equal(plaintext, []byte("blablabla")) {
+				t.Errorf("Open() plaintext = %q, want %q", plaintext, "blablabla")
+			}
+
+			if keyIndex != tt.wantKeyIndex {
+				t.Errorf("Open() keyIndex = %v, want %v", keyIndex, tt.wantKeyIndex)
+			}
+		})
+	}
+}
+
+func TestCheckKey(t *testing.T) {
+	if err := CheckKey([]byte("kkkkkkkkkkkkkkkk")); err != nil {
+		t.Errorf("CheckKey() error = %v, want nil", err)
+	}
+
+	//
+
+	if err := CheckKey([]byte("short")); err == nil {
+		t.Error("CheckKey() error = nil, want an error")
+	}
+}
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+)
+
+// matches tweets.js, tweet.js and their -partN.js siblings in large archives
+var archiveTweetsFilePattern = regexp.MustCompile(`^tweets?(-part(\d+))?\.js$`)
+
+// matches links to other tweets (i.e., quotes)
+var tweetURLPattern = regexp.MustCompile(`^https?://(www\.|mobile\.)?twitter\.com/\w+/status/\d+`)
+
+const archiveAccountFile = "account.js"
+
+// archive ZIPs are read into memory, these bound how much so a small ZIP
+// can't decompress into gigabytes (i.e., a ZIP bomb)
+var (
+	maxArchiveFileSize  uint64 = 256 << 20
+	maxArchiveTotalSize uint64 = 512 << 20
+)
+
+type archiveAccount struct {
+	Account struct {
+		Username           string `json:"username"`
+		AccountDisplayName string `json:"accountDisplayName"`
+	} `json:"account"`
+}
+
+type archiveTweet struct {
+	IDStr                string `json:"id_str"`
+	CreatedAt            string `json:"created_at"`
+	FullText             string `json:"full_text"`
+	InReplyToStatusIDStr string `json:"in_reply_to_status_id_str"`
+	InReplyToScreenName  string `json:"in_reply_to_screen_name"`
+
+	Entities struct {
+		Hashtags []struct {
+			Text string `json:"text"`
+		} `json:"hashtags"`
+
+		UserMentions []struct {
+			Name       string `json:"name"`
+			ScreenName string `json:"screen_name"`
+		} `json:"user_mentions"`
+
+		Urls []struct {
+			ExpandedURL string `json:"expanded_url"`
+		} `json:"urls"`
+	} `json:"entities"`
+}
+
+// newer archives wrap every tweet in a {"tweet": {...}} object
+type archiveTweetWrapper struct {
+	Tweet *archiveTweet `json:"tweet"`
+}
+
+type archiveTweetsService struct {
+	filesImpl func() (map[string][]byte, error)
+}
+
+// NewArchiveTweetsService returns a TweetsService backed by a downloaded
+// Twitter archive, archivePath can be the ZIP file or the extracted directory
+func NewArchiveTweetsService(archivePath string) TweetsService {
+	return &archiveTweetsService{func() (map[string][]byte, error) {
+		return archiveFiles(archivePath)
+	}}
+}
+
+// NewZipArchiveTweetsService is like NewArchiveTweetsService but reads the
+// archive ZIP from r (e.g., an uploaded file)
+func NewZipArchiveTweetsService(r io.ReaderAt, size int64) TweetsService {
+	return &archiveTweetsService{func() (map[string][]byte, error) {
+		return zipArchiveFiles(r, size)
+	}}
+}
+
+// Tweeters ignores ctx, accessToken and accessSecret since archives are read
+// without any network or OAuth
+func (service *archiveTweetsService) Tweeters(
+	ctx context.Context,
+	accessToken,
+	accessSecret string,
+) (*entities.Timeline, error,
+) {
+
+	timeline, err := service.timeline()
+
+	if err != nil {
+		return nil, &entities.InvalidInputError{Message: err.Error()}
+	}
+
+	return timeline, nil
+}
+
+// RateLimit is never known since archives don't have any
+func (service *archiveTweetsService) RateLimit(accessToken string) (
+	entities.RateLimit, bool,
+) {
+
+	return entities.RateLimit{}, false
+}
+
+func (service *archiveTweetsService) timeline() (*entities.Timeline, error) {
+	files, err := service.filesImpl()
+
+	if err != nil {
+		return nil, err
+	}
+
+	accountData, ok := files[archiveAccountFile]
+	if !ok {
+		return nil, errors.New("services: archive is missing account.js")
+	}
+
+	var accounts []archiveAccount
+	if err := decodeArchiveFile(accountData, &accounts); err != nil {
+		return nil, err
+	}
+
+	if len(accounts) == 0 || accounts[0].Account.Username == "" {
+		return nil, errors.New("services: archive account.js has no account")
+	}
+
+	account := accounts[0].Account
+	names := make([]string, 0, len(files))
+
+	for name := range files {
+		if archiveTweetsFilePattern.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, errors.New("services: archive has no tweets.js")
+	}
+
+	// tweets.js (i.e., part0) comes before tweets-part1.js, which comes before
+	// tweets-part10.js
+	sort.Slice(names, func(i, j int) bool {
+		partI, partJ := archivePart(names[i]), archivePart(names[j])
+
+		if partI != partJ {
+			return partI < partJ
+		}
+
+		return names[i] < names[j]
+	})
+
+	timeline := &entities.Timeline{Tweeters: []*entities.Tweeter{}}
+	seenIDs := make(map[string]bool)
+
+	for _, name := range names {
+		var wrappers []json.RawMessage
+		if err := decodeArchiveFile(files[name], &wrappers); err != nil {
+			return nil, err
+		}
+
+		timeline.PagesCount++
+
+		for _, raw := range wrappers {
+			tweet, err := decodeArchiveTweet(raw)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if seenIDs[tweet.IDStr] {
+				continue
+			}
+
+			seenIDs[tweet.IDStr] = true
+			timeline.TweetsCount++
+			timeline.Tweeters = append(
+				timeline.Tweeters,
+				archiveTweeter(tweet, account.AccountDisplayName, account.Username),
+			)
+		}
+	}
+
+	return timeline, nil
+}
+
+func archiveTweeter(
+	tweet *archiveTweet,
+	fullName,
+	username string,
+) *entities.Tweeter {
+
+	id, _ := strconv.ParseInt(tweet.IDStr, 10, 64)
+	createdAt, _ := time.Parse(time.RubyDate, tweet.CreatedAt)
+	tweeter := &entities.Tweeter{
+		ID:        id,
+		FullName:  fullName,
+		Username:  username,
+		CreatedAt: createdAt.UTC(),
+		Type:      entities.OriginalTweet,
+	}
+
+	mentions := tweet.Entities.UserMentions
+
+	for _, hashtag := range tweet.Entities.Hashtags {
+		tweeter.Hashtags = append(tweeter.Hashtags, hashtag.Text)
+	}
+
+	for _, link := range tweet.Entities.Urls {
+		tweeter.URLs = append(tweeter.URLs, link.ExpandedURL)
+	}
+
+	switch {
+	// archives don't keep retweeted_status, so fall back to the "RT @" prefix
+	case strings.HasPrefix(tweet.FullText, "RT @"):
+		tweeter.Type = entities.Retweet
+
+		if len(mentions) > 0 {
+			tweeter.RetweetedFullName = mentions[0].Name
+			tweeter.RetweetedUsername = mentions[0].ScreenName
+		}
+	case tweet.InReplyToStatusIDStr != "":
+		tweeter.Type = entities.Reply
+		tweeter.InReplyToUsername = tweet.InReplyToScreenName
+	default:
+		for _, link := range tweet.Entities.Urls {
+			if tweetURLPattern.MatchString(link.ExpandedURL) {
+				tweeter.Type = entities.Quote
+				break
+			}
+		}
+	}
+
+	// a retweet's first mention is its original author (i.e., "RT @")
+	if tweeter.Type == entities.Retweet && len(mentions) > 0 {
+		mentions = mentions[1:]
+	}
+
+	for _, mention := range mentions {
+		tweeter.Mentions = append(tweeter.Mentions, entities.Mention{
+			FullName: mention.Name,
+			Username: mention.ScreenName,
+		})
+	}
+
+	return tweeter
+}
+
+func decodeArchiveTweet(raw json.RawMessage) (*archiveTweet, error) {
+	var wrapper archiveTweetWrapper
+	if err := json.Unmarshal(raw, &wrapper); err != nil {
+		return nil, err
+	}
+
+	if wrapper.Tweet != nil {
+		return wrapper.Tweet, nil
+	}
+
+	var tweet archiveTweet
+	if err := json.Unmarshal(raw, &tweet); err != nil {
+		return nil, err
+	}
+
+	return &tweet, nil
+}
+
+// archive files are JavaScript assignments (e.g., window.YTD.tweet.part0 = [])
+// so the JSON value starts right after the first "="
+func decodeArchiveFile(data []byte, v interface{}) error {
+	assignment := bytes.IndexByte(data, '=')
+
+	if assignment == -1 {
+		return errors.New("services: invalid archive file")
+	}
+
+	return json.Unmarshal(data[assignment+1:], v)
+}
+
+// archivePart is the N of tweets-partN.js, tweets.js being part 0
+func archivePart(name string) int {
+	part, _ := strconv.Atoi(archiveTweetsFilePattern.FindStringSubmatch(name)[2])
+	return part
+}
+
+func isArchiveFile(name string) bool {
+	return name == archiveAccountFile || archiveTweetsFilePattern.MatchString(name)
+}
+
+func archiveFiles(archivePath string) (map[string][]byte, error) {
+	info, err := os.Stat(archivePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		file, err := os.Open(archivePath)
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer file.Close()
+		return zipArchiveFiles(file, info.Size())
+	}
+
+	files := make(map[string][]byte)
+	err = filepath.Walk(archivePath, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || !isArchiveFile(info.Name()) {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(name)
+
+		if err != nil {
+			return err
+		}
+
+		files[info.Name()] = data
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func zipArchiveFiles(r io.ReaderAt, size int64) (map[string][]byte, error) {
+	reader, err := zip.NewReader(r, size)
+
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte)
+	var totalSize uint64
+
+	for _, file := range reader.File {
+		name := path.Base(file.Name)
+
+		if file.FileInfo().IsDir() || !isArchiveFile(name) {
+			continue
+		}
+
+		if file.UncompressedSize64 > maxArchiveFileSize {
+			return nil, errors.New("services: archive file too large")
+		}
+
+		rc, err := file.Open()
+
+		if err != nil {
+			return nil, err
+		}
+
+		// the declared size can lie, so it's enforced while reading too
+		data, err := ioutil.ReadAll(io.LimitReader(rc, int64(maxArchiveFileSize)+1))
+		rc.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if uint64(len(data)) > maxArchiveFileSize {
+			return nil, errors.New("services: archive file too large")
+		}
+
+		totalSize += uint64(len(data))
+		if totalSize > maxArchiveTotalSize {
+			return nil, errors.New("services: archive too large")
+		}
+
+		files[name] = data
+	}
+
+	return files, nil
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+)
+
+var archiveFixture = map[string]string{
+	"data/account.js": `window.YTD.account.part0 = [{
+		"account": {"username": "jsmith", "accountDisplayName": "John Smith"}
+	}]`,
+
+	"data/tweets.js": `window.YTD.tweets.part0 = [
+		{"tweet": {
+			"id_str": "3",
+			"created_at": "Sat Sep 01 12:00:00 +0000 2018",
+			"full_text": "RT @jdoe: hello",
+			"entities": {"user_mentions": [{"name": "Jane Doe", "screen_name": "jdoe"}]}
+		}},
+		{"tweet": {
+			"id_str": "2",
+			"created_at": "Sat Sep 01 11:00:00 +0000 2018",
+			"full_text": "@jdoe hi #golang",
+			"in_reply_to_status_id_str": "1",
+			"in_reply_to_screen_name": "jdoe",
+			"entities": {
+				"hashtags": [{"text": "golang"}],
+				"user_mentions": [{"name": "Jane Doe", "screen_name": "jdoe"}]
+			}
+		}}
+	]`,
+
+	"data/tweets-part1.js": `window.YTD.tweets.part1 = [
+		{
+			"id_str": "1",
+			"created_at": "Sat Sep 01 10:00:00 +0000 2018",
+			"full_text": "look https://t.co/x",
+			"entities": {"urls": [{"expanded_url": "https://twitter.com/jdoe/status/1"}]}
+		},
+		{
+			"id_str": "2",
+			"created_at": "Sat Sep 01 11:00:00 +0000 2018",
+			"full_text": "@jdoe hi",
+			"in_reply_to_status_id_str": "1"
+		}
+	]`,
+
+	"data/deleted-tweets.js": `window.YTD.deleted_tweets.part0 = [{"id_str": "0"}]`,
+}
+
+var archiveFixtureTimeline = &entities.Timeline{
+	Tweeters: []*entities.Tweeter{
+		{
+			ID:                3,
+			FullName:          "John Smith",
+			Username:          "jsmith",
+			CreatedAt:         time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC),
+			Type:              entities.Retweet,
+			RetweetedFullName: "Jane Doe",
+			RetweetedUsername: "jdoe",
+		},
+		{
+			ID:        2,
+			FullName:  "John Smith",
+			Username:  "jsmith",
+			CreatedAt: time.Date(2018, 9, 1, 11, 0, 0, 0, time.UTC),
+			Type:      entities.Reply,
+
+			InReplyToUsername: "jdoe",
+
+			Hashtags: []string{"golang"},
+			Mentions: []entities.Mention{{FullName: "Jane Doe", Username: "jdoe"}},
+		},
+		{
+			ID:        1,
+			FullName:  "John Smith",
+			Username:  "jsmith",
+			CreatedAt: time.Date(2018, 9, 1, 10, 0, 0, 0, time.UTC),
+			Type:      entities.Quote,
+			URLs:      []string{"https://twitter.com/jdoe/status/1"},
+		},
+	},
+	PagesCount:  2,
+	TweetsCount: 3,
+}
+
+func TestNewZipArchiveTweetsService(t *testing.T) {
+	ctx := context.Background()
+
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+
+	for name, content := range archiveFixture {
+		file, err := writer.Create(name)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file.Write([]byte(content))
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buffer.Bytes()
+	service := NewZipArchiveTweetsService(bytes.NewReader(data), int64(len(data)))
+	got, err := service.Tweeters(ctx, "", "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, archiveFixtureTimeline) {
+		t.Errorf("archiveTweetsService.Tweeters() = %v, want %v", got, archiveFixtureTimeline)
+	}
+}
+
+func Test_zipArchiveFiles_limits(t *testing.T) {
+	defer func(maxFile, maxTotal uint64) {
+		maxArchiveFileSize, maxArchiveTotalSize = maxFile, maxTotal
+	}(maxArchiveFileSize, maxArchiveTotalSize)
+
+	zipFiles := func(files map[string]string) ([]byte, error) {
+		var buffer bytes.Buffer
+		writer := zip.NewWriter(&buffer)
+
+		for name, content := range files {
+			file, err := writer.Create(name)
+
+			if err != nil {
+				return nil, err
+			}
+
+			file.Write([]byte(content))
+		}
+
+		err := writer.Close()
+		return buffer.Bytes(), err
+	}
+
+	tests := []struct {
+		name     string
+		maxFile  uint64
+		maxTotal uint64
+		wantErr  bool
+	}{
+		{"should read archives within the limits", 64, 128, false},
+		{"should reject too large files", 16, 128, true},
+		{"should reject too large archives", 64, 64, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxArchiveFileSize, maxArchiveTotalSize = tt.maxFile, tt.maxTotal
+
+			data, err := zipFiles(map[string]string{
+				"data/account.js": strings.Repeat("a", 40),
+				"data/tweet.js":   strings.Repeat("t", 40),
+			})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = zipArchiveFiles(bytes.NewReader(data), int64(len(data)))
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("zipArchiveFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewArchiveTweetsService(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "archive")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	for name, content := range archiveFixture {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := NewArchiveTweetsService(dir).Tweeters(ctx, "", "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, archiveFixtureTimeline) {
+		t.Errorf("archiveTweetsService.Tweeters() = %v, want %v", got, archiveFixtureTimeline)
+	}
+
+	//
+	_, err = NewArchiveTweetsService(filepath.Join(dir, "missing")).Tweeters(ctx, "", "")
+
+	if err == nil {
+		t.Errorf("Should return an error when the archive doesn't exist")
+	}
+}
+
+func Test_archiveTweetsService_Tweeters(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		files map[string][]byte
+	}{
+		{
+			name:  "should return an error when account.js is missing",
+			files: map[string][]byte{"tweets.js": []byte("window.YTD.tweets.part0 = []")},
+		},
+		{
+			name:  "should return an error when tweets.js is missing",
+			files: map[string][]byte{"account.js": []byte(archiveFixture["data/account.js"])},
+		},
+		{
+			name: "should return an error when a file is malformed",
+			files: map[string][]byte{
+				"account.js": []byte(archiveFixture["data/account.js"]),
+				"tweets.js":  []byte("[]"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &archiveTweetsService{func() (map[string][]byte, error) {
+				return tt.files, nil
+			}}
+
+			if got, err := service.Tweeters(ctx, "", ""); err == nil || got != nil {
+				t.Errorf("archiveTweetsService.Tweeters() = %v, %v", got, err)
+			}
+		})
+	}
+}
+
+func Test_archiveTweetsService_Tweeters_parts(t *testing.T) {
+	part := func(n int) []byte {
+		return []byte(fmt.Sprintf(
+			`window.YTD.tweets.part%d = [{"tweet": {"id_str": "%d"}}]`,
+			n,
+			n,
+		))
+	}
+
+	service := &archiveTweetsService{func() (map[string][]byte, error) {
+		return map[string][]byte{
+			"account.js":       []byte(archiveFixture["data/account.js"]),
+			"tweets.js":        part(0),
+			"tweets-part2.js":  part(2),
+			"tweets-part10.js": part(10),
+			"tweets-part1.js":  part(1),
+		}, nil
+	}}
+
+	timeline, err := service.Tweeters(context.Background(), "", "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []int64{}
+	for _, tweeter := range timeline.Tweeters {
+		ids = append(ids, tweeter.ID)
+	}
+
+	if !reflect.DeepEqual(ids, []int64{0, 1, 2, 10}) {
+		t.Errorf("Parts should be read in numeric order: %v", ids)
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+)
+
+// CachedTweetsService is a TweetsService that keeps each account's timeline
+// for a while, so refreshing the page doesn't spend the account's rate limit
+type CachedTweetsService struct {
+	next    TweetsService
+	ttl     time.Duration
+	nowImpl func() time.Time
+
+	// refreshing views fetch timelines even when the cached ones are fresh
+	refresh bool
+	cache   *timelineCache
+}
+
+type timelineCache struct {
+	mutex   sync.Mutex
+	entries map[string]*timelineCacheEntry
+}
+
+type timelineCacheEntry struct {
+	timeline  *entities.Timeline
+	expiresAt time.Time
+}
+
+// NewCachedTweetsService caches the timelines returned by next for ttl
+func NewCachedTweetsService(
+	next TweetsService,
+	ttl time.Duration,
+) *CachedTweetsService {
+
+	return &CachedTweetsService{
+		next:    next,
+		ttl:     ttl,
+		nowImpl: time.Now,
+		cache: &timelineCache{
+			entries: make(map[string]*timelineCacheEntry),
+		},
+	}
+}
+
+// Refreshing returns a view of service that always fetches fresh timelines
+// (and caches them), services that don't cache are returned as is
+func Refreshing(service TweetsService) TweetsService {
+	cached, ok := service.(*CachedTweetsService)
+
+	if !ok {
+		return service
+	}
+
+	refreshing := *cached
+	refreshing.refresh = true
+
+	return &refreshing
+}
+
+// Tweeters returns the cached timeline of accessToken's account while it's
+// fresh, its FetchedAt is when it was actually fetched from Twitter
+func (service *CachedTweetsService) Tweeters(
+	ctx context.Context,
+	accessToken,
+	accessSecret string,
+) (*entities.Timeline, error) {
+
+	now := service.nowImpl()
+
+	if !service.refresh {
+		if timeline, ok := service.cache.get(accessToken, now); ok {
+			return service.withRateLimit(accessToken, timeline), nil
+		}
+	}
+
+	timeline, err := service.next.Tweeters(ctx, accessToken, accessSecret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cached := *timeline
+	cached.FetchedAt = now
+	service.cache.set(accessToken, &cached, now.Add(service.ttl), now)
+
+	return service.withRateLimit(accessToken, &cached), nil
+}
+
+// RateLimit blablabla
+func (service *CachedTweetsService) RateLimit(accessToken string) (
+	entities.RateLimit, bool,
+) {
+
+	return service.next.RateLimit(accessToken)
+}
+
+// withRateLimit returns a copy of timeline with the current rate limit, the
+// cached one is stale as soon as any other request is made
+func (service *CachedTweetsService) withRateLimit(
+	accessToken string,
+	timeline *entities.Timeline,
+) *entities.Timeline {
+
+	timelineCopy := *timeline
+	timelineCopy.RateLimit = nil
+
+	if rateLimit, ok := service.next.RateLimit(accessToken); ok {
+		timelineCopy.RateLimit = &rateLimit
+	}
+
+	return &timelineCopy
+}
+
+func (cache *timelineCache) get(key string, now time.Time) (
+	*entities.Timeline, bool,
+) {
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	entry, ok := cache.entries[key]
+
+	if !ok || !now.Before(entry.expiresAt) {
+		return nil, false
+	}
+
+	return entry.timeline, true
+}
+
+func (cache *timelineCache) set(
+	key string,
+	timeline *entities.Timeline,
+	expiresAt,
+	now time.Time,
+) {
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	for key, entry := range cache.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(cache.entries, key)
+		}
+	}
+
+	cache.entries[key] = &timelineCacheEntry{timeline, expiresAt}
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+)
+
+type countingTweetsService struct {
+	calls     map[string]int
+	rateLimit entities.RateLimit
+	err       error
+}
+
+func (service *countingTweetsService) Tweeters(
+	ctx context.Context,
+	accessToken,
+	accessSecret string,
+) (*entities.Timeline, error) {
+
+	service.calls[accessToken]++
+
+	if service.err != nil {
+		return nil, service.err
+	}
+
+	return &entities.Timeline{TweetsCount: uint(service.calls[accessToken])}, nil
+}
+
+func (service *countingTweetsService) RateLimit(accessToken string) (
+	entities.RateLimit, bool,
+) {
+
+	return service.rateLimit, true
+}
+
+func TestCachedTweetsService(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC)
+	next := &countingTweetsService{
+		calls:     make(map[string]int),
+		rateLimit: entities.RateLimit{Limit: 15, Remaining: 14},
+	}
+
+	service := NewCachedTweetsService(next, time.Minute)
+	service.nowImpl = func() time.Time { return now }
+
+	//
+	timeline, err := service.Tweeters(ctx, "accessToken", "accessSecret")
+
+	if err != nil ||
+		timeline.TweetsCount != 1 ||
+		!timeline.FetchedAt.Equal(now) ||
+		timeline.RateLimit == nil ||
+		timeline.RateLimit.Remaining != 14 {
+		t.Errorf("Should return the fetched timeline: %v, %v", timeline, err)
+	}
+
+	//
+	fetchedAt := now
+	now = now.Add(30 * time.Second)
+	next.rateLimit.Remaining = 13
+	timeline, err = service.Tweeters(ctx, "accessToken", "accessSecret")
+
+	if err != nil ||
+		timeline.TweetsCount != 1 ||
+		next.calls["accessToken"] != 1 ||
+		!timeline.FetchedAt.Equal(fetchedAt) ||
+		timeline.RateLimit.Remaining != 13 {
+		t.Errorf("Should return the cached timeline while it's fresh: %v", timeline)
+	}
+
+	//
+	timeline, err = service.Tweeters(ctx, "otherAccessToken", "otherAccessSecret")
+
+	if err != nil || next.calls["otherAccessToken"] != 1 {
+		t.Errorf("Should cache timelines per account")
+	}
+
+	//
+	timeline, err = Refreshing(service).Tweeters(ctx, "accessToken", "accessSecret")
+
+	if err != nil || timeline.TweetsCount != 2 || !timeline.FetchedAt.Equal(now) {
+		t.Errorf("Should fetch the timeline when refreshing: %v", timeline)
+	}
+
+	timeline, err = service.Tweeters(ctx, "accessToken", "accessSecret")
+
+	if err != nil || timeline.TweetsCount != 2 || next.calls["accessToken"] != 2 {
+		t.Errorf("Should cache refreshed timelines: %v", timeline)
+	}
+
+	//
+	now = now.Add(time.Minute)
+	timeline, err = service.Tweeters(ctx, "accessToken", "accessSecret")
+
+	if err != nil || timeline.TweetsCount != 3 {
+		t.Errorf("Should fetch the timeline again once expired: %v", timeline)
+	}
+
+	//
+	now = now.Add(time.Minute)
+	next.err = errors.New("whaaat -_-")
+
+	if _, err := service.Tweeters(ctx, "accessToken", "accessSecret"); err == nil {
+		t.Errorf("Should return errors as is")
+	}
+
+	next.err = nil
+	timeline, err = service.Tweeters(ctx, "accessToken", "accessSecret")
+
+	if err != nil || timeline.TweetsCount != 5 {
+		t.Errorf("Should not cache errors: %v", timeline)
+	}
+}
+
+func TestRefreshing(t *testing.T) {
+	service := &tweetsService{}
+
+	if Refreshing(service) != TweetsService(service) {
+		t.Errorf("Should return services that don't cache as is")
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/Ahimta/tweeters-stats-golang/storage"
+)
+
+type historyTweetsService struct {
+	next  TweetsService
+	store storage.Store
+}
+
+// NewHistoryTweetsService returns a TweetsService that saves every tweet next
+// fetches to store, and returns the account's whole stored history instead
+// of only the fetched timeline
+func NewHistoryTweetsService(
+	next TweetsService,
+	store storage.Store,
+) TweetsService {
+
+	return &historyTweetsService{next, store}
+}
+
+// Tweeters returns the stored history (including the tweets just fetched),
+// only PagesCount and RateLimit are about the fetch itself
+func (service *historyTweetsService) Tweeters(
+	ctx context.Context,
+	accessToken,
+	accessSecret string,
+) (*entities.Timeline, error) {
+
+	timeline, err := service.next.Tweeters(ctx, accessToken, accessSecret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	account := AccountKey(accessToken)
+
+	if _, err := service.store.SaveTweets(account, timeline.Tweeters); err != nil {
+		return nil, err
+	}
+
+	tweets, err := service.store.Tweets(account)
+
+	if err != nil {
+		return nil, err
+	}
+
+	history := *timeline
+	history.Tweeters = tweets
+	history.TweetsCount = uint(len(tweets))
+
+	return &history, nil
+}
+
+func (service *historyTweetsService) RateLimit(accessToken string) (
+	entities.RateLimit, bool,
+) {
+
+	return service.next.RateLimit(accessToken)
+}
+
+// AccountKey identifies the account of accessToken in storage, Twitter keeps
+// an account's access token the same across logins (until it's revoked)
+func AccountKey(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return hex.EncodeToString(sum[:])
+}
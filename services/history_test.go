@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+)
+
+type memoryStorage struct {
+	tweets map[string][]*entities.Tweeter
+	err    error
+}
+
+func (store *memoryStorage) SaveTweets(
+	account string,
+	tweets []*entities.Tweeter,
+) (int, error) {
+
+	if store.err != nil {
+		return 0, store.err
+	}
+
+	store.tweets[account] = append(tweets, store.tweets[account]...)
+	return len(tweets), nil
+}
+
+func (store *memoryStorage) Tweets(account string) ([]*entities.Tweeter, error) {
+	return store.tweets[account], store.err
+}
+
+func (store *memoryStorage) LatestID(account string) (int64, error) {
+	return 0, store.err
+}
+
+type fixedTweetsService struct {
+	timeline *entities.Timeline
+	err      error
+}
+
+func (service *fixedTweetsService) Tweeters(
+	ctx context.Context,
+	accessToken,
+	accessSecret string,
+) (*entities.Timeline, error) {
+
+	return service.timeline, service.err
+}
+
+func (service *fixedTweetsService) RateLimit(accessToken string) (
+	entities.RateLimit, bool,
+) {
+
+	return entities.RateLimit{Limit: 15}, true
+}
+
+func TestHistoryTweetsService(t *testing.T) {
+	ctx := context.Background()
+
+	stored := &entities.Tweeter{ID: 1, Username: "old"}
+	fetched := &entities.Tweeter{ID: 2, Username: "new"}
+	account := AccountKey("accessToken")
+	store := &memoryStorage{tweets: map[string][]*entities.Tweeter{
+		account: {stored},
+	}}
+	next := &fixedTweetsService{timeline: &entities.Timeline{
+		Tweeters:    []*entities.Tweeter{fetched},
+		PagesCount:  1,
+		TweetsCount: 1,
+	}}
+
+	service := NewHistoryTweetsService(next, store)
+
+	//
+	timeline, err := service.Tweeters(ctx, "accessToken", "accessSecret")
+
+	if err != nil ||
+		len(timeline.Tweeters) != 2 ||
+		timeline.Tweeters[0] != fetched ||
+		timeline.Tweeters[1] != stored ||
+		timeline.TweetsCount != 2 ||
+		timeline.PagesCount != 1 {
+		t.Errorf("Should return the whole history: %v, %v", timeline, err)
+	}
+
+	if next.timeline.TweetsCount != 1 {
+		t.Errorf("Should not modify the fetched timeline")
+	}
+
+	//
+	if _, ok := service.RateLimit("accessToken"); !ok {
+		t.Errorf("Should return next's rate limit")
+	}
+
+	//
+	store.err = errors.New("Whaaat!")
+
+	if _, err := service.Tweeters(ctx, "accessToken", "accessSecret"); err != store.err {
+		t.Errorf("Should return storage errors: %v", err)
+	}
+
+	//
+	next.err = errors.New("Whaaat!")
+
+	if _, err := service.Tweeters(ctx, "accessToken", "accessSecret"); err != next.err {
+		t.Errorf("Should return fetching errors: %v", err)
+	}
+}
+
+func TestAccountKey(t *testing.T) {
+	if AccountKey("a") != AccountKey("a") || AccountKey("a") == AccountKey("b") {
+		t.Errorf("Whaaat!")
+	}
+
+	if AccountKey("accessToken") == "accessToken" {
+		t.Errorf("Should not store access tokens as is")
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+)
+
+// ProgressFunc is called after each timeline page is fetched with the
+// timeline so far, which is only valid until it returns
+type ProgressFunc func(timeline *entities.Timeline)
+
+// progressReporter is implemented by services that can report fetch progress
+// (or pass it on to the service they wrap)
+type progressReporter interface {
+	withProgress(progress ProgressFunc) TweetsService
+}
+
+// WithProgress returns a view of service that calls progress as it fetches,
+// services that can't report progress (e.g., archives) are returned as is,
+// and cached timelines aren't reported at all
+func WithProgress(service TweetsService, progress ProgressFunc) TweetsService {
+	reporter, ok := service.(progressReporter)
+
+	if !ok {
+		return service
+	}
+
+	return reporter.withProgress(progress)
+}
+
+// progressTweetsService shares its tweetsService's rate limits
+type progressTweetsService struct {
+	*tweetsService
+	progress ProgressFunc
+}
+
+func (service *tweetsService) withProgress(
+	progress ProgressFunc,
+) TweetsService {
+
+	return &progressTweetsService{service, progress}
+}
+
+func (service *progressTweetsService) Tweeters(
+	ctx context.Context,
+	accessToken,
+	accessSecret string,
+) (*entities.Timeline, error) {
+
+	return service.TweetersSince(ctx, accessToken, accessSecret, 0)
+}
+
+func (service *progressTweetsService) TweetersSince(
+	ctx context.Context,
+	accessToken,
+	accessSecret string,
+	sinceID int64,
+) (*entities.Timeline, error) {
+
+	return service.fetch(
+		ctx,
+		accessToken,
+		accessSecret,
+		sinceID,
+		service.progress,
+	)
+}
+
+func (service *CachedTweetsService) withProgress(
+	progress ProgressFunc,
+) TweetsService {
+
+	reporting := *service
+	reporting.next = WithProgress(service.next, progress)
+
+	return &reporting
+}
+
+func (service *historyTweetsService) withProgress(
+	progress ProgressFunc,
+) TweetsService {
+
+	return &historyTweetsService{WithProgress(service.next, progress), service.store}
+}
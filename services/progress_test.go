@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+func TestWithProgress(t *testing.T) {
+	ctx := context.Background()
+
+	pages := [][]twitter.Tweet{
+		{{ID: 42, User: &twitter.User{ScreenName: "jsmith"}}},
+		{{ID: 41, User: &twitter.User{ScreenName: "jdoe"}}},
+		{},
+	}
+
+	twitterService := &tweetsService{
+		budget:  800,
+		nowImpl: time.Now,
+		tweetsImpl: func(
+			httpClient *http.Client,
+			count int,
+			maxID,
+			sinceID int64,
+		) ([]twitter.Tweet, http.Header, error) {
+
+			page := pages[0]
+			pages = pages[1:]
+
+			header := http.Header{}
+			header.Set("x-rate-limit-remaining", strconv.Itoa(10+len(pages)))
+			header.Set(
+				"x-rate-limit-reset",
+				strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10),
+			)
+
+			return page, header, nil
+		},
+		httpClientImpl: func(
+			ctx context.Context,
+			accessToken,
+			accessSecret string,
+		) (
+			*http.Client, error,
+		) {
+
+			return &http.Client{}, nil
+		},
+	}
+
+	type progress struct {
+		pagesCount, tweetsCount uint
+		remaining               int
+	}
+
+	reported := []progress{}
+	service := WithProgress(
+		NewCachedTweetsService(
+			NewHistoryTweetsService(twitterService, &memoryStorage{
+				tweets: make(map[string][]*entities.Tweeter),
+			}),
+			time.Hour,
+		),
+		func(timeline *entities.Timeline) {
+			reported = append(reported, progress{
+				timeline.PagesCount,
+				timeline.TweetsCount,
+				timeline.RateLimit.Remaining,
+			})
+		},
+	)
+
+	timeline, err := service.Tweeters(ctx, "accessToken", "accessSecret")
+
+	if err != nil || timeline.TweetsCount != 2 {
+		t.Fatalf("Whaaat! %v, %v", timeline, err)
+	}
+
+	want := []progress{{1, 1, 12}, {2, 2, 11}, {3, 2, 10}}
+
+	if len(reported) != len(want) {
+		t.Fatalf("Should report every page through decorators: %v", reported)
+	}
+
+	for i := range want {
+		if reported[i] != want[i] {
+			t.Errorf("Incorrect progress: %v, want %v", reported[i], want[i])
+		}
+	}
+
+	//
+	archive := &archiveTweetsService{}
+
+	if WithProgress(archive, nil) != TweetsService(archive) {
+		t.Errorf("Should return services that can't report as is")
+	}
+}
@@ -1,74 +1,511 @@
 package services
 
 import (
-	"errors"
+	"context"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Ahimta/tweeters-stats-golang/auth"
 	"github.com/Ahimta/tweeters-stats-golang/entities"
 	"github.com/dghubble/go-twitter/twitter"
 )
 
+// the maximum number of tweets Twitter returns in a single timeline page
+const pageSize = 200
+
+// DefaultAPIURL is the REST API base go-twitter sends requests to
+const DefaultAPIURL = "https://api.twitter.com/1.1/"
+
 // TweetsService blablabla
 type TweetsService interface {
-	Tweeters(accessToken, accessSecret string) ([]*entities.Tweeter, error)
+	Tweeters(
+		ctx context.Context,
+		accessToken,
+		accessSecret string,
+	) (*entities.Timeline, error)
+
+	// RateLimit returns the last rate limit Twitter reported for accessToken,
+	// ok is false when it's unknown or already reset
+	RateLimit(accessToken string) (rateLimit entities.RateLimit, ok bool)
+}
+
+// IncrementalTweetsService is a TweetsService that can also fetch only the
+// tweets newer than a known one (e.g., the newest stored tweet)
+type IncrementalTweetsService interface {
+	TweetsService
+
+	// TweetersSince is like Tweeters but stops at sinceID (exclusive) rather
+	// than at the budget, so no tweet newer than sinceID is skipped, a zero
+	// sinceID fetches the whole timeline (up to the budget)
+	TweetersSince(
+		ctx context.Context,
+		accessToken,
+		accessSecret string,
+		sinceID int64,
+	) (*entities.Timeline, error)
 }
 
 type tweetsService struct {
-	tweetsImpl     func(httpClient *http.Client) ([]twitter.Tweet, error)
-	httpClientImpl func(accessToken, accessSecret string) (*http.Client, error)
+	// tweetsImpl returns the response headers even when it fails, so rate
+	// limits are tracked for failed calls too
+	tweetsImpl func(
+		httpClient *http.Client,
+		count int,
+		maxID,
+		sinceID int64,
+	) ([]twitter.Tweet, http.Header, error)
+
+	httpClientImpl func(
+		ctx context.Context,
+		accessToken,
+		accessSecret string,
+	) (*http.Client, error)
+	nowImpl func() time.Time
+
+	budget uint
+	apiURL string
+
+	mutex      sync.Mutex
+	rateLimits map[string]entities.RateLimit
 }
 
 // NewTweetsService blablabla
-func NewTweetsService(client auth.Oauth1Client) TweetsService {
-	return &tweetsService{getTweets, client.HTTPClient}
+func NewTweetsService(
+	client auth.Oauth1Client,
+	budget uint,
+	apiURL string,
+) IncrementalTweetsService {
+
+	return &tweetsService{
+		tweetsImpl:     getTweets,
+		httpClientImpl: client.HTTPClient,
+		nowImpl:        time.Now,
+		budget:         budget,
+		apiURL:         apiURL,
+		rateLimits:     make(map[string]entities.RateLimit),
+	}
 }
 
-// Tweeters blablabla
+// Tweeters walks the home timeline page by page (using max_id cursors) until
+// either the timeline is exhausted or the tweets budget is consumed, it
+// doesn't call Twitter at all when accessToken's rate limit is known to be
+// exhausted (the timeline is truncated when that happens after the first
+// page), it stops as soon as ctx is done
 func (service *tweetsService) Tweeters(
+	ctx context.Context,
 	accessToken,
 	accessSecret string,
-) ([]*entities.Tweeter, error,
+) (*entities.Timeline, error,
 ) {
 
+	return service.TweetersSince(ctx, accessToken, accessSecret, 0)
+}
+
+func (service *tweetsService) TweetersSince(
+	ctx context.Context,
+	accessToken,
+	accessSecret string,
+	sinceID int64,
+) (*entities.Timeline, error) {
+
+	return service.fetch(ctx, accessToken, accessSecret, sinceID, nil)
+}
+
+// fetch calls progress (unless it's nil) after each page
+func (service *tweetsService) fetch(
+	ctx context.Context,
+	accessToken,
+	accessSecret string,
+	sinceID int64,
+	progress ProgressFunc,
+) (*entities.Timeline, error) {
+
 	if accessToken == "" || accessSecret == "" {
-		return nil, errors.New("services: missing accessToken or accessSecret")
+		return nil, &entities.UnauthenticatedError{
+			Reason: "missing accessToken or accessSecret",
+		}
+	}
+
+	httpClient, err := service.httpClientImpl(ctx, accessToken, accessSecret)
+
+	if err != nil {
+		return nil, &entities.UnauthenticatedError{Reason: err.Error()}
+	}
+
+	httpClient, err = withAPIURL(httpClient, service.apiURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	timeline := &entities.Timeline{Tweeters: []*entities.Tweeter{}}
+	seenIDs := make(map[int64]bool)
+	var maxID int64
+
+	// the budget doesn't apply when there's a sinceID, otherwise the tweets
+	// between it and the oldest fetched one would be missing
+	withinBudget := func() bool {
+		return sinceID != 0 || timeline.TweetsCount < service.budget
+	}
+
+	for withinBudget() {
+		if err := ctx.Err(); err != nil {
+			return nil, contextError(err)
+		}
+
+		count := pageSize
+		if remaining := service.budget - timeline.TweetsCount; sinceID == 0 &&
+			remaining < pageSize {
+			count = int(remaining)
+		}
+
+		if rateLimit, ok := service.RateLimit(accessToken); ok &&
+			rateLimit.Remaining <= 0 {
+			if timeline.PagesCount > 0 {
+				timeline.Truncated = true
+				break
+			}
+
+			return nil, &entities.RateLimitedError{Reset: rateLimit.Reset}
+		}
+
+		tweets, header, err := service.tweetsImpl(
+			httpClient,
+			count,
+			maxID,
+			sinceID,
+		)
+		service.updateRateLimit(accessToken, header, err)
+
+		// the call failed because ctx is done rather than because of Twitter
+		if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+			return nil, contextError(ctxErr)
+		}
+
+		// the pages fetched so far are still worth returning
+		if _, rateLimited := err.(*entities.RateLimitedError); rateLimited &&
+			timeline.PagesCount > 0 {
+			timeline.Truncated = true
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		timeline.PagesCount++
+		newTweetsCount := 0
+
+		for _, tweet := range tweets {
+			if seenIDs[tweet.ID] || !withinBudget() {
+				continue
+			}
+
+			seenIDs[tweet.ID] = true
+			newTweetsCount++
+			timeline.TweetsCount++
+
+			// a malformed timestamp only excludes the tweet from time windows
+			createdAt, _ := tweet.CreatedAtTime()
+			tweeter := &entities.Tweeter{
+				ID:        tweet.ID,
+				FullName:  tweet.User.Name,
+				Username:  tweet.User.ScreenName,
+				CreatedAt: createdAt.UTC(),
+				Type:      tweetType(tweet),
+
+				InReplyToUsername: tweet.InReplyToScreenName,
+			}
+
+			setTweetEntities(tweeter, tweet)
+
+			if retweeted := tweet.RetweetedStatus; retweeted != nil &&
+				retweeted.User != nil {
+				tweeter.RetweetedFullName = retweeted.User.Name
+				tweeter.RetweetedUsername = retweeted.User.ScreenName
+			}
+
+			timeline.Tweeters = append(timeline.Tweeters, tweeter)
+
+			if maxID == 0 || tweet.ID <= maxID {
+				maxID = tweet.ID - 1
+			}
+		}
+
+		if rateLimit, ok := service.RateLimit(accessToken); ok {
+			timeline.RateLimit = &rateLimit
+		}
+
+		if progress != nil {
+			progress(timeline)
+		}
+
+		if newTweetsCount == 0 {
+			timeline.ReachedSinceID = true
+			break
+		}
+	}
+
+	if rateLimit, ok := service.RateLimit(accessToken); ok {
+		timeline.RateLimit = &rateLimit
+	}
+
+	return timeline, nil
+}
+
+func (service *tweetsService) RateLimit(accessToken string) (
+	entities.RateLimit, bool,
+) {
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	rateLimit, ok := service.rateLimits[accessToken]
+
+	if !ok {
+		return entities.RateLimit{}, false
+	}
+
+	if !service.nowImpl().Before(rateLimit.Reset) {
+		delete(service.rateLimits, accessToken)
+		return entities.RateLimit{}, false
+	}
+
+	return rateLimit, true
+}
+
+// contextError tells timeouts apart from cancellations (e.g., the client
+// went away)
+func contextError(err error) error {
+	switch err {
+	case context.DeadlineExceeded:
+		return &entities.TimeoutError{}
+	case context.Canceled:
+		return &entities.CanceledError{}
+	}
+
+	return err
+}
+
+// updateRateLimit remembers the rate limit Twitter reported, falling back to
+// an exhausted limit when a rate-limited call had no rate limit headers
+func (service *tweetsService) updateRateLimit(
+	accessToken string,
+	header http.Header,
+	err error,
+) {
+
+	rateLimit, ok := parseRateLimit(header)
+
+	if rateLimitedErr, rateLimited := err.(*entities.RateLimitedError); !ok &&
+		rateLimited &&
+		!rateLimitedErr.Reset.IsZero() {
+		rateLimit = entities.RateLimit{Reset: rateLimitedErr.Reset}
+		ok = true
+	}
+
+	if !ok {
+		return
 	}
 
-	httpClient, err := service.httpClientImpl(accessToken, accessSecret)
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	if service.rateLimits == nil {
+		service.rateLimits = make(map[string]entities.RateLimit)
+	}
+
+	// access tokens that are never used again would stay forever, so reset
+	// limits are swept here too
+	now := service.nowImpl()
+	for key, limit := range service.rateLimits {
+		if !now.Before(limit.Reset) {
+			delete(service.rateLimits, key)
+		}
+	}
+
+	service.rateLimits[accessToken] = rateLimit
+}
+
+// apiURLTransport sends requests meant for DefaultAPIURL to another base URL
+// (e.g., a proxy or a fake server), it wraps the OAuth1 transport so requests
+// are signed with their final URL
+type apiURLTransport struct {
+	apiURL *url.URL
+	next   http.RoundTripper
+}
+
+func (transport *apiURLTransport) RoundTrip(req *http.Request) (
+	*http.Response, error,
+) {
+
+	rawURL := req.URL.String()
+
+	if !strings.HasPrefix(rawURL, DefaultAPIURL) {
+		return transport.next.RoundTrip(req)
+	}
+
+	rewrittenURL, err := transport.apiURL.Parse(
+		strings.TrimPrefix(rawURL, DefaultAPIURL),
+	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	tweets, err := service.tweetsImpl(httpClient)
+	rewrittenReq := new(http.Request)
+	*rewrittenReq = *req
+	rewrittenReq.URL = rewrittenURL
+	rewrittenReq.Host = rewrittenURL.Host
+
+	return transport.next.RoundTrip(rewrittenReq)
+}
+
+func withAPIURL(client *http.Client, apiURL string) (*http.Client, error) {
+	if apiURL == "" || apiURL == DefaultAPIURL {
+		return client, nil
+	}
+
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+
+	parsedURL, err := url.Parse(apiURL)
 
 	if err != nil {
 		return nil, err
 	}
 
-	tweeters := make([]*entities.Tweeter, 0, len(tweets))
-	for _, tweeter := range tweets {
-		tweeters = append(
-			tweeters,
-			&entities.Tweeter{
-				FullName: tweeter.User.Name,
-				Username: tweeter.User.ScreenName,
-			})
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
 	}
 
-	return tweeters, nil
+	rewrittenClient := *client
+	rewrittenClient.Transport = &apiURLTransport{parsedURL, next}
+
+	return &rewrittenClient, nil
+}
+
+func tweetType(tweet twitter.Tweet) entities.TweetType {
+	switch {
+	case tweet.RetweetedStatus != nil:
+		return entities.Retweet
+	case tweet.InReplyToStatusID != 0:
+		return entities.Reply
+	case tweet.QuotedStatus != nil:
+		return entities.Quote
+	default:
+		return entities.OriginalTweet
+	}
 }
 
-func getTweets(client *http.Client) ([]twitter.Tweet, error) {
+// setTweetEntities uses the original tweet's entities for retweets, since
+// retweets' own text is truncated and mentions the original author
+func setTweetEntities(tweeter *entities.Tweeter, tweet twitter.Tweet) {
+	tweetEntities := tweet.Entities
+	if tweet.RetweetedStatus != nil {
+		tweetEntities = tweet.RetweetedStatus.Entities
+	}
+
+	if tweetEntities == nil {
+		return
+	}
+
+	for _, hashtag := range tweetEntities.Hashtags {
+		tweeter.Hashtags = append(tweeter.Hashtags, hashtag.Text)
+	}
+
+	for _, mention := range tweetEntities.UserMentions {
+		tweeter.Mentions = append(tweeter.Mentions, entities.Mention{
+			FullName: mention.Name,
+			Username: mention.ScreenName,
+		})
+	}
+
+	for _, link := range tweetEntities.Urls {
+		tweeter.URLs = append(tweeter.URLs, link.ExpandedURL)
+	}
+}
+
+func getTweets(
+	client *http.Client,
+	count int,
+	maxID,
+	sinceID int64,
+) ([]twitter.Tweet, http.Header, error) {
+
 	twitterClient := twitter.NewClient(client)
-	tweets, _, err := twitterClient.
+	tweets, resp, err := twitterClient.
 		Timelines.
-		HomeTimeline(&twitter.HomeTimelineParams{Count: 200})
+		HomeTimeline(&twitter.HomeTimelineParams{
+			Count:   count,
+			MaxID:   maxID,
+			SinceID: sinceID,
+		})
+
+	var header http.Header
+	if resp != nil {
+		header = resp.Header
+	}
 
 	if err != nil {
-		return nil, err
+		return nil, header, twitterError(resp, err)
+	}
+
+	return tweets, header, nil
+}
+
+// twitterError classifies a failed Twitter call by its response status (resp
+// is nil when Twitter couldn't be reached at all)
+func twitterError(resp *http.Response, err error) error {
+	if resp == nil {
+		return &entities.UpstreamError{Err: err}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return &entities.UnauthenticatedError{Reason: err.Error()}
+	case http.StatusTooManyRequests:
+		return &entities.RateLimitedError{Reset: rateLimitReset(resp.Header)}
+	default:
+		return &entities.UpstreamError{Err: err}
+	}
+}
+
+// parseRateLimit parses Twitter's x-rate-limit-* headers, ok is false when
+// they're missing
+func parseRateLimit(header http.Header) (
+	rateLimit entities.RateLimit, ok bool,
+) {
+
+	remaining, err := strconv.Atoi(header.Get("x-rate-limit-remaining"))
+	reset := rateLimitReset(header)
+
+	if err != nil || reset.IsZero() {
+		return entities.RateLimit{}, false
+	}
+
+	limit, _ := strconv.Atoi(header.Get("x-rate-limit-limit"))
+
+	return entities.RateLimit{
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     reset,
+	}, true
+}
+
+// rateLimitReset parses Twitter's x-rate-limit-reset header (in epoch seconds)
+func rateLimitReset(header http.Header) time.Time {
+	reset, err := strconv.ParseInt(header.Get("x-rate-limit-reset"), 10, 64)
+
+	if err != nil {
+		return time.Time{}
 	}
 
-	return tweets, nil
+	return time.Unix(reset, 0).UTC()
 }
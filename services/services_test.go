@@ -1,10 +1,13 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"reflect"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/Ahimta/tweeters-stats-golang/auth"
 	"github.com/Ahimta/tweeters-stats-golang/entities"
@@ -16,6 +19,7 @@ func TestNewTweetsService(t *testing.T) {
 		"consumerKey",
 		"consumerSecret",
 		"callbackURL",
+		auth.TwitterEndpoint(auth.DefaultTwitterURL),
 	)
 
 	type args struct {
@@ -29,12 +33,17 @@ func TestNewTweetsService(t *testing.T) {
 		{
 			name: "should assign passed oauth1 client implementation",
 			args: args{oauth1Client},
-			want: &tweetsService{getTweets, oauth1Client.HTTPClient},
+			want: &tweetsService{
+				tweetsImpl:     getTweets,
+				httpClientImpl: oauth1Client.HTTPClient,
+				budget:         800,
+				apiURL:         DefaultAPIURL,
+			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := NewTweetsService(tt.args.oauthClient)
+			got := NewTweetsService(tt.args.oauthClient, 800, DefaultAPIURL)
 			gotHTTPClientImplPointer := reflect.
 				ValueOf(got).
 				Elem().
@@ -55,6 +64,10 @@ func TestNewTweetsService(t *testing.T) {
 }
 
 func Test_tweetsService_Tweeters(t *testing.T) {
+	ctx := context.Background()
+
+	original := entities.OriginalTweet
+
 	_httpClient := &http.Client{}
 
 	type args struct {
@@ -65,21 +78,29 @@ func Test_tweetsService_Tweeters(t *testing.T) {
 		name    string
 		service *tweetsService
 		args    args
-		want    []*entities.Tweeter
+		want    *entities.Timeline
 		wantErr bool
 	}{
 		{
 			name: "should use the underlying implementation correctly",
 
 			service: &tweetsService{
-				tweetsImpl: func(httpClient *http.Client) ([]twitter.Tweet, error) {
+				budget: 800,
+				tweetsImpl: func(
+					httpClient *http.Client,
+					count int,
+					maxID,
+					sinceID int64,
+				) ([]twitter.Tweet, http.Header, error) {
+
 					if httpClient != _httpClient {
 						t.Errorf("httpClient not passed correctly")
 					}
 
-					return []twitter.Tweet{}, nil
+					return []twitter.Tweet{}, nil, nil
 				},
 				httpClientImpl: func(
+					ctx context.Context,
 					accessToken,
 					accessSecret string,
 				) (
@@ -95,20 +116,45 @@ func Test_tweetsService_Tweeters(t *testing.T) {
 			},
 
 			args: args{"accessToken", "accessSecret"},
-			want: []*entities.Tweeter{},
+			want: &entities.Timeline{
+				Tweeters:       []*entities.Tweeter{},
+				PagesCount:     1,
+				ReachedSinceID: true,
+			},
 		},
 		{
 			name: "should process the returned tweets correctly",
 
 			service: &tweetsService{
-				tweetsImpl: func(httpClient *http.Client) ([]twitter.Tweet, error) {
+				budget: 800,
+				tweetsImpl: func(
+					httpClient *http.Client,
+					count int,
+					maxID,
+					sinceID int64,
+				) ([]twitter.Tweet, http.Header, error) {
+
+					if maxID != 0 {
+						return []twitter.Tweet{}, nil, nil
+					}
+
 					return []twitter.Tweet{
 						{
-							User: &twitter.User{Name: "John Smith", ScreenName: "jsmith"},
+							ID:                  2,
+							CreatedAt:           "Sat Sep 01 13:00:00 +0000 2018",
+							User:                &twitter.User{Name: "John Smith", ScreenName: "jsmith"},
+							InReplyToStatusID:   1,
+							InReplyToScreenName: "jdoe",
+						},
+						{
+							ID:        1,
+							CreatedAt: "Sat Sep 01 12:00:00 +0000 2018",
+							User:      &twitter.User{Name: "John Smith", ScreenName: "jsmith"},
 						},
-					}, nil
+					}, nil, nil
 				},
 				httpClientImpl: func(
+					ctx context.Context,
 					accessToken,
 					accessSecret string,
 				) (
@@ -121,21 +167,50 @@ func Test_tweetsService_Tweeters(t *testing.T) {
 			},
 
 			args: args{"accessToken", "accessSecret"},
-			want: []*entities.Tweeter{
-				{
-					FullName: "John Smith",
-					Username: "jsmith",
+			want: &entities.Timeline{
+				Tweeters: []*entities.Tweeter{
+					{
+						ID:        2,
+						FullName:  "John Smith",
+						Username:  "jsmith",
+						CreatedAt: time.Date(2018, 9, 1, 13, 0, 0, 0, time.UTC),
+						Type:      entities.Reply,
+
+						InReplyToUsername: "jdoe",
+					},
+					{
+						ID:        1,
+						FullName:  "John Smith",
+						Username:  "jsmith",
+						CreatedAt: time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC),
+						Type:      entities.OriginalTweet,
+					},
 				},
+				PagesCount:  2,
+				TweetsCount: 2,
+
+				ReachedSinceID: true,
 			},
 		},
 		{
 			name: "should return an error when httpClientImpl does",
 
 			service: &tweetsService{
-				tweetsImpl: func(httpClient *http.Client) ([]twitter.Tweet, error) {
-					return []twitter.Tweet{}, nil
+				budget: 800,
+				tweetsImpl: func(
+					httpClient *http.Client,
+					count int,
+					maxID,
+					sinceID int64,
+				) ([]twitter.Tweet, http.Header, error) {
+
+					return []twitter.Tweet{}, nil, nil
 				},
-				httpClientImpl: func(accessToken, accessSecret string) (
+				httpClientImpl: func(
+					ctx context.Context,
+					accessToken,
+					accessSecret string,
+				) (
 					*http.Client, error,
 				) {
 
@@ -150,10 +225,18 @@ func Test_tweetsService_Tweeters(t *testing.T) {
 			name: "should return an error when getTweetsImpl does",
 
 			service: &tweetsService{
-				tweetsImpl: func(httpClient *http.Client) ([]twitter.Tweet, error) {
-					return nil, errors.New("whaaat -_-")
+				budget: 800,
+				tweetsImpl: func(
+					httpClient *http.Client,
+					count int,
+					maxID,
+					sinceID int64,
+				) ([]twitter.Tweet, http.Header, error) {
+
+					return nil, nil, errors.New("whaaat -_-")
 				},
 				httpClientImpl: func(
+					ctx context.Context,
 					accessToken,
 					accessSecret string,
 				) (
@@ -167,6 +250,111 @@ func Test_tweetsService_Tweeters(t *testing.T) {
 			args:    args{"accessToken", "accessSecret"},
 			wantErr: true,
 		},
+		{
+			name: "should paginate, skip duplicates and stop at the budget",
+
+			service: &tweetsService{
+				budget: 3,
+				tweetsImpl: func(
+					httpClient *http.Client,
+					count int,
+					maxID,
+					sinceID int64,
+				) ([]twitter.Tweet, http.Header, error) {
+
+					user := &twitter.User{Name: "John Smith", ScreenName: "jsmith"}
+
+					switch maxID {
+					case 0:
+						if count != 3 {
+							t.Errorf("count not limited by budget: %v", count)
+						}
+
+						return []twitter.Tweet{{ID: 10, User: user}, {ID: 9, User: user}}, nil, nil
+					case 8:
+						if count != 1 {
+							t.Errorf("count not limited by remaining budget: %v", count)
+						}
+
+						return []twitter.Tweet{{ID: 9, User: user}, {ID: 7, User: user}}, nil, nil
+					default:
+						t.Errorf("unexpected maxID: %v", maxID)
+						return []twitter.Tweet{}, nil, nil
+					}
+				},
+				httpClientImpl: func(
+					ctx context.Context,
+					accessToken,
+					accessSecret string,
+				) (
+					*http.Client, error,
+				) {
+
+					return _httpClient, nil
+				},
+			},
+
+			args: args{"accessToken", "accessSecret"},
+			want: &entities.Timeline{
+				Tweeters: []*entities.Tweeter{
+					{ID: 10, FullName: "John Smith", Username: "jsmith", Type: original},
+					{ID: 9, FullName: "John Smith", Username: "jsmith", Type: original},
+					{ID: 7, FullName: "John Smith", Username: "jsmith", Type: original},
+				},
+				PagesCount:  2,
+				TweetsCount: 3,
+			},
+		},
+		{
+			name: "should keep the original author of retweets",
+
+			service: &tweetsService{
+				budget: 1,
+				tweetsImpl: func(
+					httpClient *http.Client,
+					count int,
+					maxID,
+					sinceID int64,
+				) ([]twitter.Tweet, http.Header, error) {
+
+					return []twitter.Tweet{
+						{
+							ID:   1,
+							User: &twitter.User{Name: "John Smith", ScreenName: "jsmith"},
+							RetweetedStatus: &twitter.Tweet{
+								User: &twitter.User{Name: "Jane Doe", ScreenName: "jdoe"},
+							},
+						},
+					}, nil, nil
+				},
+				httpClientImpl: func(
+					ctx context.Context,
+					accessToken,
+					accessSecret string,
+				) (
+					*http.Client, error,
+				) {
+
+					return _httpClient, nil
+				},
+			},
+
+			args: args{"accessToken", "accessSecret"},
+			want: &entities.Timeline{
+				Tweeters: []*entities.Tweeter{
+					{
+						ID:                1,
+						FullName:          "John Smith",
+						Username:          "jsmith",
+						Type:              entities.Retweet,
+						RetweetedFullName: "Jane Doe",
+						RetweetedUsername: "jdoe",
+					},
+				},
+				PagesCount:  1,
+				TweetsCount: 1,
+			},
+		},
 		{
 			name:    "should return an error when a required parameter is missing",
 			service: &tweetsService{},
@@ -176,7 +364,7 @@ func Test_tweetsService_Tweeters(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := tt.service.Tweeters(tt.args.accessToken, tt.args.accessSecret)
+			got, err := tt.service.Tweeters(ctx, tt.args.accessToken, tt.args.accessSecret)
 			if (err != nil) != tt.wantErr {
 				t.Errorf(
 					"tweetsService.FetchTweeters() error = %v, wantErr %v",
@@ -191,3 +379,448 @@ func Test_tweetsService_Tweeters(t *testing.T) {
 		})
 	}
 }
+
+func Test_tweetType(t *testing.T) {
+	tests := []struct {
+		name  string
+		tweet twitter.Tweet
+		want  entities.TweetType
+	}{
+		{
+			name:  "should classify a plain tweet as original",
+			tweet: twitter.Tweet{},
+			want:  entities.OriginalTweet,
+		},
+		{
+			name:  "should classify a retweet",
+			tweet: twitter.Tweet{RetweetedStatus: &twitter.Tweet{}},
+			want:  entities.Retweet,
+		},
+		{
+			name:  "should classify a reply even when it quotes a tweet",
+			tweet: twitter.Tweet{InReplyToStatusID: 1, QuotedStatus: &twitter.Tweet{}},
+			want:  entities.Reply,
+		},
+		{
+			name:  "should classify a quote",
+			tweet: twitter.Tweet{QuotedStatus: &twitter.Tweet{}},
+			want:  entities.Quote,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tweetType(tt.tweet); got != tt.want {
+				t.Errorf("tweetType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_twitterError(t *testing.T) {
+	err := errors.New("whaaat -_-")
+	rateLimited := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"X-Rate-Limit-Reset": {"1535803200"}},
+	}
+
+	tests := []struct {
+		name string
+		resp *http.Response
+		want error
+	}{
+		{
+			name: "should treat unreachable Twitter as an upstream error",
+			resp: nil,
+			want: &entities.UpstreamError{Err: err},
+		},
+		{
+			name: "should treat a 401 as an unauthenticated error",
+			resp: &http.Response{StatusCode: http.StatusUnauthorized},
+			want: &entities.UnauthenticatedError{Reason: err.Error()},
+		},
+		{
+			name: "should treat a 429 as a rate-limited error",
+			resp: rateLimited,
+			want: &entities.RateLimitedError{
+				Reset: time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "should treat a 5xx as an upstream error",
+			resp: &http.Response{StatusCode: http.StatusServiceUnavailable},
+			want: &entities.UpstreamError{Err: err},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := twitterError(tt.resp, err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("twitterError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_tweetsService_RateLimit(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC)
+	reset := now.Add(15 * time.Minute)
+	calls := 0
+
+	service := &tweetsService{
+		budget:  800,
+		nowImpl: func() time.Time { return now },
+		tweetsImpl: func(
+			httpClient *http.Client,
+			count int,
+			maxID,
+			sinceID int64,
+		) ([]twitter.Tweet, http.Header, error) {
+
+			calls++
+			header := http.Header{
+				"X-Rate-Limit-Limit":     {"15"},
+				"X-Rate-Limit-Remaining": {"0"},
+				"X-Rate-Limit-Reset":     {strconv.FormatInt(reset.Unix(), 10)},
+			}
+
+			return []twitter.Tweet{}, header, nil
+		},
+		httpClientImpl: func(
+			ctx context.Context,
+			accessToken,
+			accessSecret string,
+		) (
+			*http.Client, error,
+		) {
+
+			return &http.Client{}, nil
+		},
+	}
+
+	//
+	if _, ok := service.RateLimit("accessToken"); ok {
+		t.Errorf("Should not know the rate limit before calling Twitter")
+	}
+
+	timeline, err := service.Tweeters(ctx, "accessToken", "accessSecret")
+	want := entities.RateLimit{Limit: 15, Remaining: 0, Reset: reset}
+
+	if err != nil || timeline.RateLimit == nil || *timeline.RateLimit != want {
+		t.Errorf("Should return the rate limit with the timeline: %v", err)
+	}
+
+	if rateLimit, ok := service.RateLimit("accessToken"); !ok || rateLimit != want {
+		t.Errorf("Should remember the rate limit: %v", rateLimit)
+	}
+
+	if _, ok := service.RateLimit("otherAccessToken"); ok {
+		t.Errorf("Should track rate limits per access token")
+	}
+
+	//
+	_, err = service.Tweeters(ctx, "accessToken", "accessSecret")
+
+	if rateLimitedErr, ok := err.(*entities.RateLimitedError); !ok ||
+		!rateLimitedErr.Reset.Equal(reset) ||
+		calls != 1 {
+		t.Errorf("Should not call Twitter with an exhausted rate limit: %v", err)
+	}
+
+	//
+	now = reset
+
+	if _, err := service.Tweeters(ctx, "accessToken", "accessSecret"); err != nil ||
+		calls != 2 {
+		t.Errorf("Should call Twitter again after the reset: %v", err)
+	}
+}
+
+func Test_parseRateLimit(t *testing.T) {
+	rateLimit, ok := parseRateLimit(http.Header{
+		"X-Rate-Limit-Limit":     {"15"},
+		"X-Rate-Limit-Remaining": {"14"},
+		"X-Rate-Limit-Reset":     {"1535803200"},
+	})
+
+	want := entities.RateLimit{
+		Limit:     15,
+		Remaining: 14,
+		Reset:     time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	if !ok || rateLimit != want {
+		t.Errorf("parseRateLimit() = %v, want %v", rateLimit, want)
+	}
+
+	if _, ok := parseRateLimit(http.Header{}); ok {
+		t.Errorf("Should ignore missing headers")
+	}
+}
+
+func Test_tweetsService_truncated(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC)
+	reset := now.Add(15 * time.Minute)
+
+	newService := func(remaining string, err error) *tweetsService {
+		return &tweetsService{
+			budget:  800,
+			nowImpl: func() time.Time { return now },
+			tweetsImpl: func(
+				httpClient *http.Client,
+				count int,
+				maxID,
+				sinceID int64,
+			) ([]twitter.Tweet, http.Header, error) {
+
+				if maxID != 0 && err != nil {
+					return nil, nil, err
+				}
+
+				header := http.Header{
+					"X-Rate-Limit-Limit":     {"15"},
+					"X-Rate-Limit-Remaining": {remaining},
+					"X-Rate-Limit-Reset":     {strconv.FormatInt(reset.Unix(), 10)},
+				}
+
+				return []twitter.Tweet{
+					{ID: 42, User: &twitter.User{ScreenName: "jsmith"}},
+				}, header, nil
+			},
+			httpClientImpl: func(
+				ctx context.Context,
+				accessToken,
+				accessSecret string,
+			) (
+				*http.Client, error,
+			) {
+
+				return &http.Client{}, nil
+			},
+		}
+	}
+
+	//
+	service := newService("0", nil)
+	timeline, err := service.Tweeters(ctx, "accessToken", "accessSecret")
+
+	if err != nil ||
+		!timeline.Truncated ||
+		timeline.TweetsCount != 1 ||
+		timeline.RateLimit == nil {
+		t.Errorf("Should return the pages fetched before the rate limit ran out: %v, %v", timeline, err)
+	}
+
+	//
+	service = newService("14", &entities.RateLimitedError{Reset: reset})
+	timeline, err = service.Tweeters(ctx, "accessToken", "accessSecret")
+
+	if err != nil || !timeline.Truncated || timeline.TweetsCount != 1 {
+		t.Errorf("Should return the pages fetched before a 429: %v, %v", timeline, err)
+	}
+
+	//
+	service = newService("14", nil)
+	service.updateRateLimit("otherAccessToken", http.Header{
+		"X-Rate-Limit-Remaining": {"14"},
+		"X-Rate-Limit-Reset":     {strconv.FormatInt(now.Unix(), 10)},
+	}, nil)
+	service.updateRateLimit("accessToken", http.Header{
+		"X-Rate-Limit-Remaining": {"14"},
+		"X-Rate-Limit-Reset":     {strconv.FormatInt(reset.Unix(), 10)},
+	}, nil)
+
+	if _, ok := service.rateLimits["otherAccessToken"]; ok {
+		t.Errorf("Should sweep reset rate limits")
+	}
+}
+
+func Test_tweetsService_TweetersSince(t *testing.T) {
+	ctx := context.Background()
+
+	service := &tweetsService{
+		budget: 800,
+		tweetsImpl: func(
+			httpClient *http.Client,
+			count int,
+			maxID,
+			sinceID int64,
+		) ([]twitter.Tweet, http.Header, error) {
+
+			if sinceID != 41 {
+				t.Errorf("sinceID not passed correctly: %v", sinceID)
+			}
+
+			if maxID != 0 {
+				return []twitter.Tweet{}, nil, nil
+			}
+
+			return []twitter.Tweet{
+				{ID: 42, User: &twitter.User{ScreenName: "jsmith"}},
+			}, nil, nil
+		},
+		httpClientImpl: func(
+			ctx context.Context,
+			accessToken,
+			accessSecret string,
+		) (
+			*http.Client, error,
+		) {
+
+			return &http.Client{}, nil
+		},
+	}
+
+	timeline, err := service.TweetersSince(ctx, "accessToken", "accessSecret", 41)
+
+	if err != nil || timeline.TweetsCount != 1 || timeline.Tweeters[0].ID != 42 {
+		t.Errorf("Should return the tweets since sinceID: %v, %v", timeline, err)
+	}
+}
+
+func Test_tweetsService_TweetersSince_budget(t *testing.T) {
+	ctx := context.Background()
+
+	service := &tweetsService{
+		budget: 1,
+		tweetsImpl: func(
+			httpClient *http.Client,
+			count int,
+			maxID,
+			sinceID int64,
+		) ([]twitter.Tweet, http.Header, error) {
+
+			tweets := []twitter.Tweet{}
+
+			for id := int64(44); id > sinceID; id-- {
+				if maxID == 0 || id <= maxID {
+					tweets = append(tweets, twitter.Tweet{
+						ID:   id,
+						User: &twitter.User{ScreenName: "jsmith"},
+					})
+				}
+
+				if len(tweets) == count {
+					break
+				}
+			}
+
+			return tweets, nil, nil
+		},
+		httpClientImpl: func(
+			ctx context.Context,
+			accessToken,
+			accessSecret string,
+		) (
+			*http.Client, error,
+		) {
+
+			return &http.Client{}, nil
+		},
+	}
+
+	//
+	timeline, err := service.Tweeters(ctx, "accessToken", "accessSecret")
+
+	if err != nil || timeline.TweetsCount != 1 || timeline.ReachedSinceID {
+		t.Errorf("Should stop at the budget: %v, %v", timeline, err)
+	}
+
+	//
+	timeline, err = service.TweetersSince(ctx, "accessToken", "accessSecret", 41)
+
+	if err != nil || timeline.TweetsCount != 3 || !timeline.ReachedSinceID {
+		t.Errorf("Should keep going past the budget until sinceID: %v, %v", timeline, err)
+	}
+}
+
+func Test_tweetsService_context(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+
+	service := &tweetsService{
+		budget: 800,
+		tweetsImpl: func(
+			httpClient *http.Client,
+			count int,
+			maxID,
+			sinceID int64,
+		) ([]twitter.Tweet, http.Header, error) {
+
+			calls++
+			cancel()
+
+			return nil, nil, &entities.UpstreamError{Err: errors.New("canceled")}
+		},
+		httpClientImpl: func(
+			ctx context.Context,
+			accessToken,
+			accessSecret string,
+		) (
+			*http.Client, error,
+		) {
+
+			return &http.Client{}, nil
+		},
+	}
+
+	//
+	_, err := service.Tweeters(ctx, "accessToken", "accessSecret")
+
+	if _, ok := err.(*entities.CanceledError); !ok {
+		t.Errorf("Should return a CanceledError: %v", err)
+	}
+
+	//
+	ctx, cancel = context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+
+	_, err = service.Tweeters(ctx, "accessToken", "accessSecret")
+
+	if _, ok := err.(*entities.TimeoutError); !ok || calls != 1 {
+		t.Errorf("Should return a TimeoutError without calling Twitter: %v", err)
+	}
+}
+
+func Test_setTweetEntities(t *testing.T) {
+	tweetEntities := &twitter.Entities{
+		Hashtags:     []twitter.HashtagEntity{{Text: "golang"}},
+		UserMentions: []twitter.MentionEntity{{Name: "Jane Doe", ScreenName: "jdoe"}},
+		Urls:         []twitter.URLEntity{{ExpandedURL: "https://golang.org/"}},
+	}
+	want := &entities.Tweeter{
+		Hashtags: []string{"golang"},
+		Mentions: []entities.Mention{{FullName: "Jane Doe", Username: "jdoe"}},
+		URLs:     []string{"https://golang.org/"},
+	}
+
+	//
+	tweeter := &entities.Tweeter{}
+	setTweetEntities(tweeter, twitter.Tweet{Entities: tweetEntities})
+
+	if !reflect.DeepEqual(tweeter, want) {
+		t.Errorf("Should copy the tweet's entities: %v", tweeter)
+	}
+
+	//
+	tweeter = &entities.Tweeter{}
+	setTweetEntities(tweeter, twitter.Tweet{
+		Entities: &twitter.Entities{
+			UserMentions: []twitter.MentionEntity{{ScreenName: "jsmith"}},
+		},
+		RetweetedStatus: &twitter.Tweet{Entities: tweetEntities},
+	})
+
+	if !reflect.DeepEqual(tweeter, want) {
+		t.Errorf("Should copy the original tweet's entities for retweets: %v", tweeter)
+	}
+
+	//
+	tweeter = &entities.Tweeter{}
+	setTweetEntities(tweeter, twitter.Tweet{})
+
+	if !reflect.DeepEqual(tweeter, &entities.Tweeter{}) {
+		t.Errorf("Should handle tweets without entities: %v", tweeter)
+	}
+}
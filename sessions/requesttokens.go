@@ -0,0 +1,112 @@
+package sessions
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrExpired is returned for request tokens taken after their TTL
+	ErrExpired = errors.New("sessions: request token expired")
+
+	// ErrUsed is returned for request tokens that were already taken
+	ErrUsed = errors.New("sessions: request token already used")
+)
+
+// RequestTokenStore keeps OAuth1 request secrets between the login redirect
+// and the callback, each request token can only be taken once
+type RequestTokenStore interface {
+	Save(requestToken, requestSecret string) error
+
+	// Take returns the secret of requestToken and forgets it, it returns
+	// ErrNotFound, ErrExpired or ErrUsed when the token can't be used
+	Take(requestToken string) (requestSecret string, err error)
+}
+
+type requestTokenEntry struct {
+	secret    string
+	expiresAt time.Time
+	used      bool
+}
+
+type memoryRequestTokenStore struct {
+	ttl     time.Duration
+	nowImpl func() time.Time
+
+	mutex   sync.Mutex
+	entries map[string]*requestTokenEntry
+}
+
+// NewRequestTokenStore returns an in-memory RequestTokenStore, request tokens
+// are short-lived so losing them on restart only means logging in again
+func NewRequestTokenStore(ttl time.Duration) RequestTokenStore {
+	return &memoryRequestTokenStore{
+		ttl:     ttl,
+		nowImpl: time.Now,
+		entries: make(map[string]*requestTokenEntry),
+	}
+}
+
+func (store *memoryRequestTokenStore) Save(
+	requestToken,
+	requestSecret string,
+) error {
+
+	if requestToken == "" || requestSecret == "" {
+		return errors.New("sessions: missing requestToken or requestSecret")
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := store.nowImpl()
+
+	// used entries are kept until they expire to tell replays apart from
+	// unknown tokens, so expired ones are swept here instead
+	for key, entry := range store.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(store.entries, key)
+		}
+	}
+
+	if _, ok := store.entries[hashID(requestToken)]; ok {
+		return errors.New("sessions: duplicate requestToken")
+	}
+
+	store.entries[hashID(requestToken)] = &requestTokenEntry{
+		secret:    requestSecret,
+		expiresAt: now.Add(store.ttl),
+	}
+
+	return nil
+}
+
+func (store *memoryRequestTokenStore) Take(requestToken string) (
+	string, error,
+) {
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	entry, ok := store.entries[hashID(requestToken)]
+
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	if !store.nowImpl().Before(entry.expiresAt) {
+		delete(store.entries, hashID(requestToken))
+		return "", ErrExpired
+	}
+
+	if entry.used {
+		return "", ErrUsed
+	}
+
+	secret := entry.secret
+	entry.secret = ""
+	entry.used = true
+
+	return secret, nil
+}
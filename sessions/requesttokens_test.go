@@ -0,0 +1,58 @@
+package sessions
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRequestTokenStore(t *testing.T) {
+	store := NewRequestTokenStore(time.Minute).(*memoryRequestTokenStore)
+
+	//
+	if err := store.Save("requestToken", "requestSecret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save("requestToken", "otherSecret"); err == nil {
+		t.Errorf("Should not overwrite a request token")
+	}
+
+	if err := store.Save("", "requestSecret"); err == nil {
+		t.Errorf("Should return an error when a parameter is missing")
+	}
+
+	//
+	secret, err := store.Take("requestToken")
+
+	if err != nil || secret != "requestSecret" {
+		t.Errorf("Should return the saved secret: %v, %v", secret, err)
+	}
+
+	if _, err := store.Take("requestToken"); err != ErrUsed {
+		t.Errorf("Should only return a secret once: %v", err)
+	}
+
+	if _, err := store.Take("blablabla"); err != ErrNotFound {
+		t.Errorf("Should return ErrNotFound for unknown tokens: %v", err)
+	}
+
+	//
+	if err := store.Save("otherToken", "otherSecret"); err != nil {
+		t.Fatal(err)
+	}
+
+	store.nowImpl = func() time.Time { return time.Now().Add(2 * time.Minute) }
+
+	if _, err := store.Take("otherToken"); err != ErrExpired {
+		t.Errorf("Should return ErrExpired for expired tokens: %v", err)
+	}
+
+	//
+	if err := store.Save("newToken", "newSecret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := store.entries[hashID("requestToken")]; ok {
+		t.Errorf("Should sweep expired tokens")
+	}
+}
@@ -0,0 +1,279 @@
+// Package sessions keeps OAuth access tokens server-side, clients only get an
+// opaque random session ID
+package sessions
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrNotFound is returned for unknown, deleted or expired sessions
+var ErrNotFound = errors.New("sessions: session not found")
+
+var bucketName = []byte("sessions")
+
+// Session blablabla
+type Session struct {
+	AccessToken  string    `json:"accessToken"`
+	AccessSecret string    `json:"accessSecret"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// Store blablabla
+type Store interface {
+	// Create returns the ID of a new session that expires after the store's TTL
+	Create(accessToken, accessSecret string) (id string, err error)
+	Get(id string) (*Session, error)
+	Delete(id string) error
+}
+
+type memoryStore struct {
+	ttl     time.Duration
+	nowImpl func() time.Time
+
+	mutex    sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewMemoryStore returns a Store that loses its sessions on restart
+func NewMemoryStore(ttl time.Duration) Store {
+	return &memoryStore{
+		ttl:      ttl,
+		nowImpl:  time.Now,
+		sessions: make(map[string]*Session),
+	}
+}
+
+func (store *memoryStore) Create(accessToken, accessSecret string) (
+	string, error,
+) {
+
+	if accessToken == "" || accessSecret == "" {
+		return "", errors.New("sessions: missing accessToken or accessSecret")
+	}
+
+	id, err := newID()
+
+	if err != nil {
+		return "", err
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	now := store.nowImpl()
+
+	// sessions that are never read again would stay forever, so expired ones
+	// are swept here too
+	for key, session := range store.sessions {
+		if !now.Before(session.ExpiresAt) {
+			delete(store.sessions, key)
+		}
+	}
+
+	store.sessions[hashID(id)] = &Session{
+		AccessToken:  accessToken,
+		AccessSecret: accessSecret,
+		ExpiresAt:    now.Add(store.ttl),
+	}
+
+	return id, nil
+}
+
+func (store *memoryStore) Get(id string) (*Session, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	key := hashID(id)
+	session, ok := store.sessions[key]
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if !store.nowImpl().Before(session.ExpiresAt) {
+		delete(store.sessions, key)
+		return nil, ErrNotFound
+	}
+
+	sessionCopy := *session
+	return &sessionCopy, nil
+}
+
+func (store *memoryStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	delete(store.sessions, hashID(id))
+	return nil
+}
+
+// BoltStore is a Store persisted to a local BoltDB file
+type BoltStore struct {
+	db      *bolt.DB
+	ttl     time.Duration
+	nowImpl func() time.Time
+}
+
+// NewBoltStore opens (or creates) the BoltDB file at path, callers should
+// Close it when done
+func NewBoltStore(path string, ttl time.Duration) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketName)
+		return err
+	})
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db, ttl, time.Now}, nil
+}
+
+// Create blablabla
+func (store *BoltStore) Create(accessToken, accessSecret string) (
+	string, error,
+) {
+
+	if accessToken == "" || accessSecret == "" {
+		return "", errors.New("sessions: missing accessToken or accessSecret")
+	}
+
+	id, err := newID()
+
+	if err != nil {
+		return "", err
+	}
+
+	now := store.nowImpl()
+
+	data, err := json.Marshal(&Session{
+		AccessToken:  accessToken,
+		AccessSecret: accessSecret,
+		ExpiresAt:    now.Add(store.ttl),
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketName)
+
+		// like memoryStore, sessions that are never read again would stay
+		// forever, so expired ones are swept here too
+		var expired [][]byte
+
+		err := bucket.ForEach(func(key, value []byte) error {
+			var session Session
+
+			if err := json.Unmarshal(value, &session); err != nil {
+				return err
+			}
+
+			if !now.Before(session.ExpiresAt) {
+				expired = append(expired, key)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		for _, key := range expired {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		return bucket.Put([]byte(hashID(id)), data)
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Get only opens a write transaction to delete an expired session, so reads
+// don't queue behind BoltDB's single writer
+func (store *BoltStore) Get(id string) (*Session, error) {
+	var session *Session
+	key := []byte(hashID(id))
+
+	err := store.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketName).Get(key)
+
+		if data == nil {
+			return ErrNotFound
+		}
+
+		session = &Session{}
+		return json.Unmarshal(data, session)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !store.nowImpl().Before(session.ExpiresAt) {
+		err := store.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(bucketName).Delete(key)
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, ErrNotFound
+	}
+
+	return session, nil
+}
+
+// Delete blablabla
+func (store *BoltStore) Delete(id string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Delete([]byte(hashID(id)))
+	})
+}
+
+// Close blablabla
+func (store *BoltStore) Close() error {
+	return store.db.Close()
+}
+
+func newID() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// only hashes of session IDs are stored, so the IDs themselves (i.e., the
+// cookie values) never end up on disk
+func hashID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
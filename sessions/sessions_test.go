@@ -0,0 +1,152 @@
+package sessions
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func testStore(t *testing.T, store Store, setNow func(now func() time.Time)) {
+	//
+	id, err := store.Create("accessToken", "accessSecret")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := store.Get(id)
+
+	if err != nil ||
+		session.AccessToken != "accessToken" ||
+		session.AccessSecret != "accessSecret" {
+		t.Errorf("Should return the created session: %v, %v", session, err)
+	}
+
+	//
+	otherID, err := store.Create("accessToken", "accessSecret")
+
+	if err != nil || otherID == id {
+		t.Errorf("Should create a new random ID for every session")
+	}
+
+	//
+	if _, err := store.Create("accessToken", ""); err == nil {
+		t.Errorf("Should return an error when a parameter is missing")
+	}
+
+	//
+	if _, err := store.Get("blablabla"); err != ErrNotFound {
+		t.Errorf("Should return ErrNotFound for unknown sessions: %v", err)
+	}
+
+	//
+	if err := store.Delete(id); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Get(id); err != ErrNotFound {
+		t.Errorf("Should return ErrNotFound for deleted sessions: %v", err)
+	}
+
+	//
+	setNow(func() time.Time { return time.Now().Add(2 * time.Hour) })
+
+	if _, err := store.Get(otherID); err != ErrNotFound {
+		t.Errorf("Should return ErrNotFound for expired sessions: %v", err)
+	}
+
+	setNow(time.Now)
+
+	if _, err := store.Get(otherID); err != ErrNotFound {
+		t.Errorf("Should delete expired sessions: %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(time.Hour).(*memoryStore)
+
+	testStore(t, store, func(now func() time.Time) {
+		store.nowImpl = now
+	})
+
+	//
+	id, err := store.Create("accessToken", "accessSecret")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.nowImpl = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	if _, err := store.Create("accessToken", "accessSecret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := store.sessions[hashID(id)]; ok {
+		t.Errorf("Should sweep expired sessions")
+	}
+}
+
+func TestBoltStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sessions")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sessions.db")
+	store, err := NewBoltStore(path, time.Hour)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testStore(t, store, func(now func() time.Time) {
+		store.nowImpl = now
+	})
+
+	//
+	id, err := store.Create("accessToken", "accessSecret")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.Close()
+	store, err = NewBoltStore(path, time.Hour)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer store.Close()
+
+	if _, err := store.Get(id); err != nil {
+		t.Errorf("Should keep sessions across restarts: %v", err)
+	}
+
+	//
+	store.nowImpl = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	if _, err := store.Create("accessToken", "accessSecret"); err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketName).Get([]byte(hashID(id))) != nil {
+			t.Errorf("Should sweep expired sessions")
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"encoding/json"
+
+	"github.com/Ahimta/tweeters-stats-golang/seal"
+)
+
+// collectionTokens are sealed with AES-GCM (with the cookie keys) so access
+// tokens never end up on disk in plaintext
+type collectionTokens struct {
+	AccessToken  string `json:"accessToken"`
+	AccessSecret string `json:"accessSecret"`
+}
+
+// collectionRecord is how collections are stored, the account is
+// authenticated too so sealed tokens can't be moved to another account
+type collectionRecord struct {
+	Account string `json:"account"`
+	Tokens  []byte `json:"tokens"`
+}
+
+func sealCollection(key []byte, collection Collection) (*collectionRecord, error) {
+	plaintext, err := json.Marshal(&collectionTokens{
+		AccessToken:  collection.AccessToken,
+		AccessSecret: collection.AccessSecret,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := seal.Seal(key, plaintext, []byte(collection.Account))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &collectionRecord{Account: collection.Account, Tokens: tokens}, nil
+}
+
+// openCollection tries every key in turn like cookies do, stale is true when
+// record isn't sealed with the current key (i.e., keys[0])
+func openCollection(keys [][]byte, record *collectionRecord) (
+	collection Collection, stale bool, err error,
+) {
+
+	plaintext, keyIndex, err := seal.Open(keys, record.Tokens, []byte(record.Account))
+
+	if err != nil {
+		return Collection{}, false, err
+	}
+
+	var tokens collectionTokens
+	if err := json.Unmarshal(plaintext, &tokens); err != nil {
+		return Collection{}, false, err
+	}
+
+	return Collection{
+		record.Account,
+		tokens.AccessToken,
+		tokens.AccessSecret,
+	}, keyIndex > 0, nil
+}
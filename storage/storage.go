@@ -0,0 +1,378 @@
+// Package storage keeps every fetched tweet in a local BoltDB file, so stats
+// can cover more than what Twitter returns in a single timeline fetch
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/Ahimta/tweeters-stats-golang/seal"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	accountsBucket    = []byte("accounts")
+	collectionsBucket = []byte("collections")
+)
+
+// Store blablabla
+type Store interface {
+	// SaveTweets stores tweets under account, tweets it already has (by ID)
+	// are skipped, it returns how many tweets were new
+	SaveTweets(account string, tweets []*entities.Tweeter) (int, error)
+
+	// Tweets returns every tweet stored under account, newest first
+	Tweets(account string) ([]*entities.Tweeter, error)
+
+	// LatestID returns the ID of the newest tweet stored under account, it's
+	// zero when there's none
+	LatestID(account string) (int64, error)
+}
+
+// Collection is an account opted in to background collection, with the
+// tokens used to fetch its timeline (which are only stored sealed)
+type Collection struct {
+	Account      string `json:"account"`
+	AccessToken  string `json:"accessToken"`
+	AccessSecret string `json:"accessSecret"`
+}
+
+// CollectionStore keeps the accounts opted in to background collection
+type CollectionStore interface {
+	// SaveCollection opts collection.Account in, replacing its old tokens
+	SaveCollection(collection Collection) error
+	DeleteCollection(account string) error
+	Collections() ([]Collection, error)
+}
+
+// tweetRecord is how tweets are stored, so entities can change without
+// breaking existing databases
+type tweetRecord struct {
+	ID        int64     `json:"id"`
+	FullName  string    `json:"fullName"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"createdAt"`
+	Type      string    `json:"type"`
+
+	RetweetedFullName string `json:"retweetedFullName,omitempty"`
+	RetweetedUsername string `json:"retweetedUsername,omitempty"`
+	InReplyToUsername string `json:"inReplyToUsername,omitempty"`
+
+	Hashtags []string        `json:"hashtags,omitempty"`
+	Mentions []mentionRecord `json:"mentions,omitempty"`
+	URLs     []string        `json:"urls,omitempty"`
+}
+
+type mentionRecord struct {
+	FullName string `json:"fullName"`
+	Username string `json:"username"`
+}
+
+func newTweetRecord(tweet *entities.Tweeter) *tweetRecord {
+	record := &tweetRecord{
+		ID:                tweet.ID,
+		FullName:          tweet.FullName,
+		Username:          tweet.Username,
+		CreatedAt:         tweet.CreatedAt,
+		Type:              string(tweet.Type),
+		RetweetedFullName: tweet.RetweetedFullName,
+		RetweetedUsername: tweet.RetweetedUsername,
+		InReplyToUsername: tweet.InReplyToUsername,
+		Hashtags:          tweet.Hashtags,
+		URLs:              tweet.URLs,
+	}
+
+	for _, mention := range tweet.Mentions {
+		record.Mentions = append(record.Mentions, mentionRecord(mention))
+	}
+
+	return record
+}
+
+func (record *tweetRecord) tweeter() *entities.Tweeter {
+	tweeter := &entities.Tweeter{
+		ID:                record.ID,
+		FullName:          record.FullName,
+		Username:          record.Username,
+		CreatedAt:         record.CreatedAt,
+		Type:              entities.TweetType(record.Type),
+		RetweetedFullName: record.RetweetedFullName,
+		RetweetedUsername: record.RetweetedUsername,
+		InReplyToUsername: record.InReplyToUsername,
+		Hashtags:          record.Hashtags,
+		URLs:              record.URLs,
+	}
+
+	for _, mention := range record.Mentions {
+		tweeter.Mentions = append(tweeter.Mentions, entities.Mention(mention))
+	}
+
+	return tweeter
+}
+
+// BoltStore is a Store persisted to a local BoltDB file, with a bucket of
+// tweets (keyed by ID) per account
+type BoltStore struct {
+	db *bolt.DB
+
+	// collection tokens are sealed with keys[0], the others only open them
+	keys [][]byte
+}
+
+// NewBoltStore opens (or creates) the BoltDB file at path, callers should
+// Close it when done, keys are AES keys (e.g., the cookie keys) and every
+// collection not sealed with the first one is sealed again
+func NewBoltStore(path string, keys [][]byte) (*BoltStore, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("storage: missing key")
+	}
+
+	if err := seal.CheckKey(keys[0]); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+
+	if err != nil {
+		return nil, err
+	}
+
+	store := &BoltStore{db, keys}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{accountsBucket, collectionsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		return store.resealCollections(tx)
+	})
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// resealCollections seals collections sealed with an old key with the current
+// key, so old keys can be dropped after a restart
+func (store *BoltStore) resealCollections(tx *bolt.Tx) error {
+	bucket := tx.Bucket(collectionsBucket)
+	stale := []Collection{}
+
+	err := bucket.ForEach(func(key, data []byte) error {
+		var record collectionRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return err
+		}
+
+		collection, isStale, err := openCollection(store.keys, &record)
+
+		if err != nil {
+			return err
+		}
+
+		if isStale {
+			stale = append(stale, collection)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// buckets can't be changed while iterating over them
+	for _, collection := range stale {
+		if err := store.putCollection(tx, collection); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *BoltStore) putCollection(tx *bolt.Tx, collection Collection) error {
+	record, err := sealCollection(store.keys[0], collection)
+
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(record)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(collectionsBucket).Put([]byte(collection.Account), data)
+}
+
+// SaveTweets blablabla
+func (store *BoltStore) SaveTweets(
+	account string,
+	tweets []*entities.Tweeter,
+) (int, error) {
+
+	if account == "" {
+		return 0, errors.New("storage: missing account")
+	}
+
+	added := 0
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.
+			Bucket(accountsBucket).
+			CreateBucketIfNotExists([]byte(account))
+
+		if err != nil {
+			return err
+		}
+
+		for _, tweet := range tweets {
+			key := tweetKey(tweet.ID)
+
+			if tweet.ID == 0 || bucket.Get(key) != nil {
+				continue
+			}
+
+			data, err := json.Marshal(newTweetRecord(tweet))
+
+			if err != nil {
+				return err
+			}
+
+			if err := bucket.Put(key, data); err != nil {
+				return err
+			}
+
+			added++
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return added, nil
+}
+
+// Tweets blablabla
+func (store *BoltStore) Tweets(account string) ([]*entities.Tweeter, error) {
+	tweets := []*entities.Tweeter{}
+
+	err := store.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(accountsBucket).Bucket([]byte(account))
+
+		if bucket == nil {
+			return nil
+		}
+
+		cursor := bucket.Cursor()
+		for key, data := cursor.Last(); key != nil; key, data = cursor.Prev() {
+			var record tweetRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				return err
+			}
+
+			tweets = append(tweets, record.tweeter())
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tweets, nil
+}
+
+// LatestID blablabla
+func (store *BoltStore) LatestID(account string) (int64, error) {
+	var latestID int64
+
+	err := store.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(accountsBucket).Bucket([]byte(account))
+
+		if bucket == nil {
+			return nil
+		}
+
+		if key, _ := bucket.Cursor().Last(); key != nil {
+			latestID = int64(binary.BigEndian.Uint64(key))
+		}
+
+		return nil
+	})
+
+	return latestID, err
+}
+
+// SaveCollection blablabla
+func (store *BoltStore) SaveCollection(collection Collection) error {
+	if collection.Account == "" ||
+		collection.AccessToken == "" ||
+		collection.AccessSecret == "" {
+		return errors.New("storage: missing account, accessToken or accessSecret")
+	}
+
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return store.putCollection(tx, collection)
+	})
+}
+
+// DeleteCollection blablabla
+func (store *BoltStore) DeleteCollection(account string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(collectionsBucket).Delete([]byte(account))
+	})
+}
+
+// Collections blablabla
+func (store *BoltStore) Collections() ([]Collection, error) {
+	collections := []Collection{}
+
+	err := store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(collectionsBucket).ForEach(func(key, data []byte) error {
+			var record collectionRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				return err
+			}
+
+			collection, _, err := openCollection(store.keys, &record)
+
+			if err != nil {
+				return err
+			}
+
+			collections = append(collections, collection)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+// Close blablabla
+func (store *BoltStore) Close() error {
+	return store.db.Close()
+}
+
+// big-endian keys keep tweets sorted by ID (i.e., by creation time)
+func tweetKey(id int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	testKey    = []byte("kkkkkkkkkkkkkkkkkkkkkkkkkkkkkkkk")
+	testOldKey = []byte("oooooooooooooooo")
+)
+
+func TestBoltStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "history.db")
+	store, err := NewBoltStore(path, [][]byte{testKey})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createdAt := time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC)
+	older := &entities.Tweeter{
+		ID:        1,
+		FullName:  "Abdullah",
+		Username:  "Ahimta",
+		CreatedAt: createdAt,
+		Type:      entities.OriginalTweet,
+	}
+	newer := &entities.Tweeter{
+		ID:                2,
+		FullName:          "Abdullah",
+		Username:          "Ahimta",
+		CreatedAt:         createdAt.Add(time.Hour),
+		Type:              entities.Retweet,
+		RetweetedFullName: "Someone",
+		RetweetedUsername: "someone",
+		Hashtags:          []string{"golang"},
+		Mentions:          []entities.Mention{{FullName: "Jane Doe", Username: "jdoe"}},
+		URLs:              []string{"https://golang.org/"},
+	}
+
+	//
+	added, err := store.SaveTweets("account", []*entities.Tweeter{newer, older})
+
+	if err != nil || added != 2 {
+		t.Errorf("Should save new tweets: %v, %v", added, err)
+	}
+
+	//
+	added, err = store.SaveTweets("account", []*entities.Tweeter{
+		newer,
+		{ID: 0, Username: "nobody"},
+	})
+
+	if err != nil || added != 0 {
+		t.Errorf("Should skip saved tweets and tweets without IDs: %v, %v", added, err)
+	}
+
+	//
+	if _, err := store.SaveTweets("", []*entities.Tweeter{older}); err == nil {
+		t.Errorf("Should return an error when account is missing")
+	}
+
+	//
+	tweets, err := store.Tweets("account")
+	want := []*entities.Tweeter{newer, older}
+
+	if err != nil || !reflect.DeepEqual(tweets, want) {
+		t.Errorf("Should return saved tweets newest first: %v, %v", tweets, err)
+	}
+
+	//
+	tweets, err = store.Tweets("otherAccount")
+
+	if err != nil || len(tweets) != 0 {
+		t.Errorf("Should keep accounts separate: %v, %v", tweets, err)
+	}
+
+	//
+	if latestID, err := store.LatestID("account"); err != nil || latestID != 2 {
+		t.Errorf("Should return the newest tweet's ID: %v, %v", latestID, err)
+	}
+
+	if latestID, err := store.LatestID("otherAccount"); err != nil || latestID != 0 {
+		t.Errorf("Should return zero without tweets: %v, %v", latestID, err)
+	}
+
+	//
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = NewBoltStore(path, [][]byte{testKey})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer store.Close()
+
+	tweets, err = store.Tweets("account")
+
+	if err != nil || !reflect.DeepEqual(tweets, want) {
+		t.Errorf("Should persist tweets across restarts: %v, %v", tweets, err)
+	}
+}
+
+func TestBoltStore_Collections(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "history.db")
+	store, err := NewBoltStore(path, [][]byte{testKey})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer store.Close()
+
+	collection := Collection{"account", "accessToken", "accessSecret"}
+
+	//
+	if err := store.SaveCollection(collection); err != nil {
+		t.Fatal(err)
+	}
+
+	collections, err := store.Collections()
+
+	if err != nil || !reflect.DeepEqual(collections, []Collection{collection}) {
+		t.Errorf("Should return saved collections: %v, %v", collections, err)
+	}
+
+	store.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(collectionsBucket).Get([]byte("account"))
+
+		if bytes.Contains(data, []byte("accessToken")) ||
+			bytes.Contains(data, []byte("accessSecret")) {
+			t.Errorf("Should seal collection tokens: %s", data)
+		}
+
+		return nil
+	})
+
+	//
+	collection.AccessToken = "newAccessToken"
+
+	if err := store.SaveCollection(collection); err != nil {
+		t.Fatal(err)
+	}
+
+	collections, err = store.Collections()
+
+	if err != nil || !reflect.DeepEqual(collections, []Collection{collection}) {
+		t.Errorf("Should replace the tokens of saved collections: %v, %v", collections, err)
+	}
+
+	//
+	if err := store.SaveCollection(Collection{Account: "account"}); err == nil {
+		t.Errorf("Should return an error when a token is missing")
+	}
+
+	//
+	if err := store.DeleteCollection("account"); err != nil {
+		t.Fatal(err)
+	}
+
+	collections, err = store.Collections()
+
+	if err != nil || len(collections) != 0 {
+		t.Errorf("Should delete collections: %v, %v", collections, err)
+	}
+}
+
+func TestNewBoltStore_reseal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "history.db")
+	store, err := NewBoltStore(path, [][]byte{testOldKey})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collection := Collection{"account", "accessToken", "accessSecret"}
+
+	if err := store.SaveCollection(collection); err != nil {
+		t.Fatal(err)
+	}
+
+	store.Close()
+
+	//
+	store, err = NewBoltStore(path, [][]byte{testKey, testOldKey})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collections, err := store.Collections()
+
+	if err != nil || !reflect.DeepEqual(collections, []Collection{collection}) {
+		t.Errorf("Should open old key collections: %v, %v", collections, err)
+	}
+
+	store.Close()
+
+	//
+	store, err = NewBoltStore(path, [][]byte{testKey})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collections, err = store.Collections()
+
+	if err != nil || !reflect.DeepEqual(collections, []Collection{collection}) {
+		t.Errorf("Should seal collections with the current key: %v, %v", collections, err)
+	}
+
+	store.Close()
+
+	//
+	if _, err := NewBoltStore(path, [][]byte{testOldKey}); err == nil {
+		t.Errorf("Should return an error for collections sealed with unknown keys")
+	}
+
+	//
+	if _, err := NewBoltStore(path, nil); err == nil {
+		t.Errorf("Should return an error when the key is missing")
+	}
+}
@@ -0,0 +1,92 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/Ahimta/tweeters-stats-golang/services"
+)
+
+// AmplifiedStatsResult blablabla
+type AmplifiedStatsResult struct {
+	Stats []*entities.AmplifiedStats
+
+	PagesCount  uint
+	TweetsCount uint
+}
+
+// AmplifiedStats ranks original authors by how many times they reached the
+// timeline through someone else's retweet
+func AmplifiedStats(
+	ctx context.Context,
+	tweetsService services.TweetsService,
+	accessToken,
+	accessSecret string,
+	window TimeWindow,
+) (
+	*AmplifiedStatsResult, error,
+) {
+
+	timeline, err := fetchTimeline(ctx, tweetsService, accessToken, accessSecret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	statsByUsername := make(map[string]*entities.AmplifiedStats)
+	retweetersByUsername := make(map[string]map[string]bool)
+
+	for _, tweeter := range timeline.Tweeters {
+		if tweeter.Type != entities.Retweet ||
+			tweeter.RetweetedUsername == "" ||
+			tweeter.RetweetedUsername == tweeter.Username ||
+			!window.Contains(tweeter.CreatedAt) {
+			continue
+		}
+
+		username := tweeter.RetweetedUsername
+		amplifiedStats, ok := statsByUsername[username]
+
+		if !ok {
+			amplifiedStats = &entities.AmplifiedStats{
+				FullName: tweeter.RetweetedFullName,
+				Username: username,
+			}
+			statsByUsername[username] = amplifiedStats
+			retweetersByUsername[username] = make(map[string]bool)
+		}
+
+		amplifiedStats.RetweetsCount++
+
+		if retweeters := retweetersByUsername[username]; !retweeters[tweeter.Username] {
+			retweeters[tweeter.Username] = true
+			amplifiedStats.RetweetersCount++
+		}
+	}
+
+	stats := make([]*entities.AmplifiedStats, 0, len(statsByUsername))
+
+	for _, amplifiedStats := range statsByUsername {
+		stats = append(stats, amplifiedStats)
+	}
+
+	// by retweets, then by retweeters (descending)
+	sortRanking(
+		stats,
+		StatsQuery{},
+		func(i, j int) int {
+			if c := compareCounts(stats[i].RetweetsCount, stats[j].RetweetsCount); c != 0 {
+				return c
+			}
+
+			return compareCounts(stats[i].RetweetersCount, stats[j].RetweetersCount)
+		},
+		func(i int) string { return stats[i].Username },
+	)
+
+	return &AmplifiedStatsResult{
+		Stats:       stats,
+		PagesCount:  timeline.PagesCount,
+		TweetsCount: timeline.TweetsCount,
+	}, nil
+}
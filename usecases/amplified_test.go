@@ -0,0 +1,134 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+)
+
+func TestAmplifiedStats(t *testing.T) {
+	ctx := context.Background()
+
+	//
+	result, err := AmplifiedStats(
+		ctx,
+		&tweetsService{
+			tweeters: []*entities.Tweeter{
+				&entities.Tweeter{
+					Username:          "jsmith0",
+					Type:              entities.Retweet,
+					RetweetedFullName: "Jane Doe",
+					RetweetedUsername: "jdoe",
+				},
+				&entities.Tweeter{
+					Username:          "jsmith1",
+					Type:              entities.Retweet,
+					RetweetedFullName: "Jane Doe",
+					RetweetedUsername: "jdoe",
+				},
+				&entities.Tweeter{
+					Username:          "jsmith1",
+					Type:              entities.Retweet,
+					RetweetedFullName: "Jane Doe",
+					RetweetedUsername: "jdoe",
+				},
+				&entities.Tweeter{
+					Username:          "jsmith0",
+					Type:              entities.Retweet,
+					RetweetedFullName: "John Smith1",
+					RetweetedUsername: "jsmith1",
+				},
+				&entities.Tweeter{
+					Username:          "jsmith2",
+					Type:              entities.Retweet,
+					RetweetedFullName: "John Smith2",
+					RetweetedUsername: "jsmith2",
+				},
+				&entities.Tweeter{
+					Username: "jdoe",
+					Type:     entities.OriginalTweet,
+				},
+			},
+			err: nil,
+		},
+		"blablabla",
+		"blablabla",
+		TimeWindow{},
+	)
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(result.Stats) != 2 {
+		t.Errorf("Should only rank authors retweeted by others")
+	}
+
+	if stats := result.Stats[0]; stats.Username != "jdoe" ||
+		stats.FullName != "Jane Doe" ||
+		stats.RetweetsCount != 3 ||
+		stats.RetweetersCount != 2 {
+
+		t.Errorf("Should count retweets and distinct retweeters: %v", stats)
+	}
+
+	if result.PagesCount != 1 || result.TweetsCount != 6 {
+		t.Errorf("Should return the pages and tweets consumed by TweetService")
+	}
+
+	//
+	retweet := func(username, retweetedUsername string) *entities.Tweeter {
+		return &entities.Tweeter{
+			Username:          username,
+			Type:              entities.Retweet,
+			RetweetedUsername: retweetedUsername,
+		}
+	}
+
+	result, err = AmplifiedStats(
+		ctx,
+		&tweetsService{tweeters: []*entities.Tweeter{
+			retweet("r1", "d"),
+			retweet("r1", "a"),
+			retweet("r1", "a"),
+			retweet("r1", "b"),
+			retweet("r2", "b"),
+			retweet("r1", "c"),
+		}},
+		"blablabla",
+		"blablabla",
+		TimeWindow{},
+	)
+
+	usernames := []string{}
+	for _, stats := range result.Stats {
+		usernames = append(usernames, stats.Username)
+	}
+
+	if err != nil || strings.Join(usernames, ",") != "b,a,c,d" {
+		t.Errorf("Should break ties by retweeters and then username: %v, %v", usernames, err)
+	}
+
+	//
+	result, err = AmplifiedStats(
+		ctx,
+		&tweetsService{err: errors.New("blablabla")},
+		"blablabla",
+		"blablabla",
+		TimeWindow{},
+	)
+
+	if err == nil || result != nil {
+		t.Errorf("Should return an error when TweetService returns an error")
+	}
+
+	//
+	result, err = AmplifiedStats(ctx, &tweetsService{}, "", "", TimeWindow{})
+
+	if err == nil || result != nil {
+		t.Errorf("Should return an error when a parameter is missing")
+	}
+}
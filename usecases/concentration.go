@@ -0,0 +1,53 @@
+package usecases
+
+import "github.com/Ahimta/tweeters-stats-golang/entities"
+
+// summarizeStats sets each tweeter's percentages and measures how
+// concentrated tweets are, stats are left ranked by tweets count
+func summarizeStats(stats []*entities.TweeterStats) *entities.StatsSummary {
+	sortStats(stats, StatsQuery{SortBy: SortByCount})
+
+	summary := &entities.StatsSummary{TweetersCount: uint(len(stats))}
+
+	for _, tweeterStats := range stats {
+		summary.TweetsCount += tweeterStats.TweetsCount
+	}
+
+	if summary.TweetsCount == 0 {
+		return summary
+	}
+
+	total := float64(summary.TweetsCount)
+	topTenth := (len(stats) + 9) / 10
+	var cumulative uint
+
+	for i, tweeterStats := range stats {
+		share := float64(tweeterStats.TweetsCount) / total
+		cumulative += tweeterStats.TweetsCount
+
+		tweeterStats.Percent = 100 * share
+		tweeterStats.CumulativePercent = 100 * float64(cumulative) / total
+		summary.Herfindahl += share * share
+
+		if i == topTenth-1 {
+			summary.TopTenthPercent = tweeterStats.CumulativePercent
+		}
+	}
+
+	summary.Gini = gini(stats, total)
+	return summary
+}
+
+// gini is the mean absolute difference between tweeters' counts over twice
+// the mean count, stats have to be ranked by tweets count (descending)
+func gini(stats []*entities.TweeterStats, total float64) float64 {
+	n := float64(len(stats))
+	var weighted float64
+
+	// ranks are ascending in the usual formula, hence n - i
+	for i, tweeterStats := range stats {
+		weighted += (n - float64(i)) * float64(tweeterStats.TweetsCount)
+	}
+
+	return 2*weighted/(n*total) - (n+1)/n
+}
@@ -0,0 +1,84 @@
+package usecases
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+)
+
+func TestTweetersStats_summary(t *testing.T) {
+	ctx := context.Background()
+
+	tweeters := []*entities.Tweeter{}
+	counts := map[string]int{"a": 6, "b": 2, "c": 1, "d": 1}
+
+	for username, count := range counts {
+		for i := 0; i < count; i++ {
+			tweeters = append(tweeters, &entities.Tweeter{Username: username})
+		}
+	}
+
+	result, err := TweetersStats(
+		ctx,
+		&tweetsService{tweeters: tweeters},
+		"blablabla",
+		"blablabla",
+		TimeWindow{},
+		StatsQuery{SortBy: SortByUsername, Ascending: true, Offset: 1},
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	near := func(x, y float64) bool { return math.Abs(x-y) < 1e-9 }
+	summary := result.Summary
+
+	if summary.TweetsCount != 10 || summary.TweetersCount != 4 {
+		t.Errorf("Should count every tweet and tweeter: %+v", summary)
+	}
+
+	// (4*6 + 3*2 + 2*1 + 1*1) * 2 / (4*10) - 5/4
+	if !near(summary.Gini, 0.4) {
+		t.Errorf("Whaaat! gini = %v", summary.Gini)
+	}
+
+	if !near(summary.Herfindahl, 0.42) {
+		t.Errorf("Whaaat! herfindahl = %v", summary.Herfindahl)
+	}
+
+	if !near(summary.TopTenthPercent, 60) {
+		t.Errorf("Should count at least one tweeter as the top 10%%")
+	}
+
+	// percentages don't depend on the query's order or paging
+	if len(result.Stats) != 3 || result.Stats[0].Username != "b" {
+		t.Fatalf("Whaaat! %+v", result.Stats)
+	}
+
+	if !near(result.Stats[0].Percent, 20) ||
+		!near(result.Stats[0].CumulativePercent, 80) ||
+		!near(result.Stats[2].CumulativePercent, 100) {
+
+		t.Errorf("Should rank cumulative percentages by tweets count")
+	}
+
+	//
+	summary = summarizeStats([]*entities.TweeterStats{})
+
+	if summary.TweetsCount != 0 || summary.Gini != 0 || summary.Herfindahl != 0 {
+		t.Errorf("Should summarize an empty timeline as zeros")
+	}
+
+	//
+	summary = summarizeStats([]*entities.TweeterStats{
+		{Username: "a", TweetsCount: 3},
+		{Username: "b", TweetsCount: 3},
+	})
+
+	if !near(summary.Gini, 0) || !near(summary.Herfindahl, 0.5) {
+		t.Errorf("Should summarize an evenly spread timeline: %+v", summary)
+	}
+}
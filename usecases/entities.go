@@ -0,0 +1,235 @@
+package usecases
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/Ahimta/tweeters-stats-golang/services"
+)
+
+// EntityStatsResult blablabla
+type EntityStatsResult struct {
+	Stats []*entities.EntityStats
+
+	// how many entities there are before StatsQuery's limit and offset
+	TotalCount uint
+
+	PagesCount  uint
+	TweetsCount uint
+}
+
+// compareEntityStats is compareStats for entities, username sorts by name
+// (e.g., a mentioned user's username or a hashtag)
+func compareEntityStats(x, y *entities.EntityStats, sortBy StatsSortKey) int {
+	switch sortBy {
+	case SortByUsername:
+		return strings.Compare(strings.ToLower(x.Name), strings.ToLower(y.Name))
+	case SortByFullName:
+		return strings.Compare(strings.ToLower(x.FullName), strings.ToLower(y.FullName))
+	default:
+		return compareCounts(x.TweetsCount, y.TweetsCount)
+	}
+}
+
+// entityRef is an entity of a tweet, key identifies it (e.g., a lowercased
+// hashtag) while name and fullName are what's shown
+type entityRef struct {
+	key      string
+	name     string
+	fullName string
+}
+
+// HashtagsStats ranks hashtags (case-insensitively) by how many tweets use
+// them, sorted and paged by query like tweeters stats
+func HashtagsStats(
+	ctx context.Context,
+	tweetsService services.TweetsService,
+	accessToken,
+	accessSecret string,
+	window TimeWindow,
+	query StatsQuery,
+) (
+	*EntityStatsResult, error,
+) {
+
+	return entityStats(
+		ctx,
+		tweetsService,
+		accessToken,
+		accessSecret,
+		window,
+		query,
+		func(tweeter *entities.Tweeter) []entityRef {
+			refs := make([]entityRef, 0, len(tweeter.Hashtags))
+
+			for _, hashtag := range tweeter.Hashtags {
+				refs = append(refs, entityRef{strings.ToLower(hashtag), hashtag, ""})
+			}
+
+			return refs
+		},
+	)
+}
+
+// MentionsStats ranks users by how many tweets mention them
+func MentionsStats(
+	ctx context.Context,
+	tweetsService services.TweetsService,
+	accessToken,
+	accessSecret string,
+	window TimeWindow,
+	query StatsQuery,
+) (
+	*EntityStatsResult, error,
+) {
+
+	return entityStats(
+		ctx,
+		tweetsService,
+		accessToken,
+		accessSecret,
+		window,
+		query,
+		func(tweeter *entities.Tweeter) []entityRef {
+			refs := make([]entityRef, 0, len(tweeter.Mentions))
+
+			for _, mention := range tweeter.Mentions {
+				refs = append(refs, entityRef{
+					strings.ToLower(mention.Username),
+					mention.Username,
+					mention.FullName,
+				})
+			}
+
+			return refs
+		},
+	)
+}
+
+// DomainsStats ranks the domains of linked URLs (without "www.") by how many
+// tweets link to them
+func DomainsStats(
+	ctx context.Context,
+	tweetsService services.TweetsService,
+	accessToken,
+	accessSecret string,
+	window TimeWindow,
+	query StatsQuery,
+) (
+	*EntityStatsResult, error,
+) {
+
+	return entityStats(
+		ctx,
+		tweetsService,
+		accessToken,
+		accessSecret,
+		window,
+		query,
+		func(tweeter *entities.Tweeter) []entityRef {
+			refs := make([]entityRef, 0, len(tweeter.URLs))
+
+			for _, link := range tweeter.URLs {
+				if domain := urlDomain(link); domain != "" {
+					refs = append(refs, entityRef{domain, domain, ""})
+				}
+			}
+
+			return refs
+		},
+	)
+}
+
+// entityStats counts every entity at most once per tweet
+func entityStats(
+	ctx context.Context,
+	tweetsService services.TweetsService,
+	accessToken,
+	accessSecret string,
+	window TimeWindow,
+	query StatsQuery,
+	refs func(tweeter *entities.Tweeter) []entityRef,
+) (
+	*EntityStatsResult, error,
+) {
+
+	if query.SortBy == SortByRetweets || query.SortBy == SortByReplies {
+		return nil, &entities.InvalidInputError{
+			Message: "sort must be count, username or fullName",
+		}
+	}
+
+	timeline, err := fetchTimeline(ctx, tweetsService, accessToken, accessSecret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	statsByKey := make(map[string]*entities.EntityStats)
+	tweetersByKey := make(map[string]map[string]bool)
+
+	for _, tweeter := range timeline.Tweeters {
+		if !window.Contains(tweeter.CreatedAt) {
+			continue
+		}
+
+		seenKeys := make(map[string]bool)
+
+		for _, ref := range refs(tweeter) {
+			if seenKeys[ref.key] {
+				continue
+			}
+
+			seenKeys[ref.key] = true
+			stats, ok := statsByKey[ref.key]
+
+			if !ok {
+				stats = &entities.EntityStats{Name: ref.name, FullName: ref.fullName}
+				statsByKey[ref.key] = stats
+				tweetersByKey[ref.key] = make(map[string]bool)
+			}
+
+			stats.TweetsCount++
+
+			if tweeters := tweetersByKey[ref.key]; !tweeters[tweeter.Username] {
+				tweeters[tweeter.Username] = true
+				stats.TweetersCount++
+			}
+		}
+	}
+
+	stats := make([]*entities.EntityStats, 0, len(statsByKey))
+
+	for _, entityStats := range statsByKey {
+		stats = append(stats, entityStats)
+	}
+
+	sortRanking(
+		stats,
+		query,
+		func(i, j int) int { return compareEntityStats(stats[i], stats[j], query.SortBy) },
+		func(i int) string { return stats[i].Name },
+	)
+
+	start, end := pageRange(len(stats), query)
+
+	return &EntityStatsResult{
+		Stats:       stats[start:end],
+		TotalCount:  uint(len(stats)),
+		PagesCount:  timeline.PagesCount,
+		TweetsCount: timeline.TweetsCount,
+	}, nil
+}
+
+// urlDomain is empty for malformed URLs
+func urlDomain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
@@ -0,0 +1,162 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+)
+
+var entitiesTweetsService = &tweetsService{
+	tweeters: []*entities.Tweeter{
+		{
+			Username: "jsmith",
+			Hashtags: []string{"Golang", "golang", "gophers"},
+			Mentions: []entities.Mention{{FullName: "Jane Doe", Username: "jdoe"}},
+			URLs: []string{
+				"https://www.golang.org/doc",
+				"https://golang.org/pkg",
+			},
+		},
+		{
+			Username: "jsmith",
+			Hashtags: []string{"golang"},
+			Mentions: []entities.Mention{{FullName: "Jane Doe", Username: "JDoe"}},
+			URLs:     []string{"https://blog.golang.org/", "%"},
+		},
+		{
+			Username: "jdoe",
+			Hashtags: []string{"GoLang"},
+			Mentions: []entities.Mention{{FullName: "John Smith", Username: "jsmith"}},
+		},
+	},
+}
+
+func TestHashtagsStats(t *testing.T) {
+	ctx := context.Background()
+
+	result, err := HashtagsStats(
+		ctx,
+		entitiesTweetsService,
+		"blablabla",
+		"blablabla",
+		TimeWindow{},
+		StatsQuery{},
+	)
+
+	want := []*entities.EntityStats{
+		{Name: "Golang", TweetsCount: 3, TweetersCount: 2},
+		{Name: "gophers", TweetsCount: 1, TweetersCount: 1},
+	}
+
+	if err != nil || !reflect.DeepEqual(result.Stats, want) {
+		t.Errorf("Should count hashtags once per tweet: %v, %v", result, err)
+	}
+
+	if result.PagesCount != 1 || result.TweetsCount != 3 {
+		t.Errorf("Should return the pages and tweets consumed by TweetService")
+	}
+
+	//
+	result, err = HashtagsStats(
+		ctx,
+		entitiesTweetsService,
+		"blablabla",
+		"blablabla",
+		TimeWindow{},
+		StatsQuery{Limit: 1},
+	)
+
+	if err != nil ||
+		len(result.Stats) != 1 ||
+		result.Stats[0].Name != "Golang" ||
+		result.TotalCount != 2 {
+		t.Errorf("Should only return the top limit hashtags: %v, %v", result, err)
+	}
+
+	//
+	result, err = HashtagsStats(
+		ctx,
+		entitiesTweetsService,
+		"blablabla",
+		"blablabla",
+		TimeWindow{},
+		StatsQuery{SortBy: SortByUsername, Ascending: false, Offset: 1},
+	)
+
+	if err != nil || len(result.Stats) != 1 || result.Stats[0].Name != "Golang" {
+		t.Errorf("Should sort and page hashtags like tweeters: %v, %v", result, err)
+	}
+
+	//
+	if _, err := HashtagsStats(
+		ctx,
+		entitiesTweetsService,
+		"blablabla",
+		"blablabla",
+		TimeWindow{},
+		StatsQuery{SortBy: SortByRetweets},
+	); err == nil {
+		t.Errorf("Should reject sorting hashtags by retweets")
+	}
+
+	//
+	_, err = HashtagsStats(
+		ctx,
+		&tweetsService{err: errors.New("Whaaat!")},
+		"blablabla",
+		"blablabla",
+		TimeWindow{},
+		StatsQuery{},
+	)
+
+	if err == nil {
+		t.Errorf("Should return TweetsService errors")
+	}
+}
+
+func TestMentionsStats(t *testing.T) {
+	ctx := context.Background()
+
+	result, err := MentionsStats(
+		ctx,
+		entitiesTweetsService,
+		"blablabla",
+		"blablabla",
+		TimeWindow{},
+		StatsQuery{},
+	)
+
+	want := []*entities.EntityStats{
+		{Name: "jdoe", FullName: "Jane Doe", TweetsCount: 2, TweetersCount: 1},
+		{Name: "jsmith", FullName: "John Smith", TweetsCount: 1, TweetersCount: 1},
+	}
+
+	if err != nil || !reflect.DeepEqual(result.Stats, want) {
+		t.Errorf("Should count mentioned users: %v, %v", result, err)
+	}
+}
+
+func TestDomainsStats(t *testing.T) {
+	ctx := context.Background()
+
+	result, err := DomainsStats(
+		ctx,
+		entitiesTweetsService,
+		"blablabla",
+		"blablabla",
+		TimeWindow{},
+		StatsQuery{},
+	)
+
+	want := []*entities.EntityStats{
+		{Name: "blog.golang.org", TweetsCount: 1, TweetersCount: 1},
+		{Name: "golang.org", TweetsCount: 1, TweetersCount: 1},
+	}
+
+	if err != nil || !reflect.DeepEqual(result.Stats, want) {
+		t.Errorf("Should count linked domains: %v, %v", result, err)
+	}
+}
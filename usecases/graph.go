@@ -0,0 +1,178 @@
+package usecases
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/Ahimta/tweeters-stats-golang/services"
+)
+
+// InteractionGraphResult blablabla
+type InteractionGraphResult struct {
+	Graph *entities.Graph
+
+	PagesCount  uint
+	TweetsCount uint
+}
+
+type graphEdgeKey struct {
+	source string
+	target string
+}
+
+type graphBuilder struct {
+	nodes map[string]*entities.GraphNode
+	edges map[graphEdgeKey]*entities.GraphEdge
+}
+
+// InteractionGraph links tweeters to the users they reply to, mention and
+// retweet, a reply's mention of the replied-to user isn't counted twice and
+// self-interactions are left out
+func InteractionGraph(
+	ctx context.Context,
+	tweetsService services.TweetsService,
+	accessToken,
+	accessSecret string,
+	window TimeWindow,
+) (
+	*InteractionGraphResult, error,
+) {
+
+	timeline, err := fetchTimeline(ctx, tweetsService, accessToken, accessSecret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	builder := &graphBuilder{
+		nodes: make(map[string]*entities.GraphNode),
+		edges: make(map[graphEdgeKey]*entities.GraphEdge),
+	}
+
+	for _, tweeter := range timeline.Tweeters {
+		if !window.Contains(tweeter.CreatedAt) || tweeter.Username == "" {
+			continue
+		}
+
+		source := builder.node(tweeter.Username, tweeter.FullName)
+		source.TweetsCount++
+
+		if tweeter.Type == entities.Retweet && tweeter.RetweetedUsername != "" {
+			target := builder.node(tweeter.RetweetedUsername, tweeter.RetweetedFullName)
+
+			if edge := builder.edge(source, target); edge != nil {
+				edge.Retweets++
+			}
+		}
+
+		if tweeter.Type == entities.Reply && tweeter.InReplyToUsername != "" {
+			target := builder.node(tweeter.InReplyToUsername, "")
+
+			if edge := builder.edge(source, target); edge != nil {
+				edge.Replies++
+			}
+		}
+
+		// a retweet's mentions are the original tweet's rather than the
+		// retweeter's
+		if tweeter.Type == entities.Retweet {
+			continue
+		}
+
+		mentioned := map[string]bool{
+			strings.ToLower(tweeter.InReplyToUsername): tweeter.Type == entities.Reply,
+		}
+
+		for _, mention := range tweeter.Mentions {
+			target := builder.node(mention.Username, mention.FullName)
+
+			if mentioned[target.ID] {
+				continue
+			}
+
+			mentioned[target.ID] = true
+
+			if edge := builder.edge(source, target); edge != nil {
+				edge.Mentions++
+			}
+		}
+	}
+
+	return &InteractionGraphResult{
+		Graph:       builder.graph(),
+		PagesCount:  timeline.PagesCount,
+		TweetsCount: timeline.TweetsCount,
+	}, nil
+}
+
+// node fills in fullName when the node was first seen without one (e.g., as
+// a reply target)
+func (builder *graphBuilder) node(username, fullName string) *entities.GraphNode {
+	id := strings.ToLower(username)
+	node, ok := builder.nodes[id]
+
+	if !ok {
+		node = &entities.GraphNode{ID: id, Username: username}
+		builder.nodes[id] = node
+	}
+
+	if node.FullName == "" {
+		node.FullName = fullName
+	}
+
+	return node
+}
+
+// edge is nil for self-interactions, callers count the interaction's kind
+// while Weight is counted here
+func (builder *graphBuilder) edge(
+	source,
+	target *entities.GraphNode,
+) *entities.GraphEdge {
+
+	if source.ID == target.ID {
+		return nil
+	}
+
+	key := graphEdgeKey{source.ID, target.ID}
+	edge, ok := builder.edges[key]
+
+	if !ok {
+		edge = &entities.GraphEdge{Source: source.ID, Target: target.ID}
+		builder.edges[key] = edge
+	}
+
+	edge.Weight++
+	return edge
+}
+
+func (builder *graphBuilder) graph() *entities.Graph {
+	graph := &entities.Graph{
+		Nodes: make([]entities.GraphNode, 0, len(builder.nodes)),
+		Edges: make([]entities.GraphEdge, 0, len(builder.edges)),
+	}
+
+	for _, node := range builder.nodes {
+		graph.Nodes = append(graph.Nodes, *node)
+	}
+
+	for _, edge := range builder.edges {
+		graph.Edges = append(graph.Edges, *edge)
+	}
+
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].ID < graph.Nodes[j].ID
+	})
+
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].Source != graph.Edges[j].Source {
+			return graph.Edges[i].Source < graph.Edges[j].Source
+		}
+
+		return graph.Edges[i].Target < graph.Edges[j].Target
+	})
+
+	return graph
+}
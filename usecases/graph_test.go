@@ -0,0 +1,86 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+)
+
+func TestInteractionGraph(t *testing.T) {
+	ctx := context.Background()
+
+	result, err := InteractionGraph(
+		ctx,
+		&tweetsService{
+			tweeters: []*entities.Tweeter{
+				{
+					Username:          "jsmith",
+					FullName:          "John Smith",
+					Type:              entities.Reply,
+					InReplyToUsername: "JDoe",
+					Mentions: []entities.Mention{
+						{FullName: "Jane Doe", Username: "jdoe"},
+						{FullName: "Jack Black", Username: "jblack"},
+					},
+				},
+				{
+					Username:          "jsmith",
+					FullName:          "John Smith",
+					Type:              entities.Retweet,
+					RetweetedFullName: "Jane Doe",
+					RetweetedUsername: "jdoe",
+					Mentions:          []entities.Mention{{FullName: "Jim Beam", Username: "jbeam"}},
+				},
+				{
+					Username: "jdoe",
+					FullName: "Jane Doe",
+					Type:     entities.OriginalTweet,
+					Mentions: []entities.Mention{{FullName: "Jane Doe", Username: "jdoe"}},
+				},
+			},
+		},
+		"blablabla",
+		"blablabla",
+		TimeWindow{},
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &entities.Graph{
+		Nodes: []entities.GraphNode{
+			{ID: "jblack", Username: "jblack", FullName: "Jack Black"},
+			{ID: "jdoe", Username: "JDoe", FullName: "Jane Doe", TweetsCount: 1},
+			{ID: "jsmith", Username: "jsmith", FullName: "John Smith", TweetsCount: 2},
+		},
+		Edges: []entities.GraphEdge{
+			{Source: "jsmith", Target: "jblack", Weight: 1, Mentions: 1},
+			{Source: "jsmith", Target: "jdoe", Weight: 2, Replies: 1, Retweets: 1},
+		},
+	}
+
+	if !reflect.DeepEqual(result.Graph, want) {
+		t.Errorf("InteractionGraph(ctx, ) = %v, want %v", result.Graph, want)
+	}
+
+	if result.PagesCount != 1 || result.TweetsCount != 3 {
+		t.Errorf("Should return the pages and tweets consumed by TweetService")
+	}
+
+	//
+	_, err = InteractionGraph(
+		ctx,
+		&tweetsService{err: errors.New("Whaaat!")},
+		"blablabla",
+		"blablabla",
+		TimeWindow{},
+	)
+
+	if err == nil {
+		t.Errorf("Should return TweetsService errors")
+	}
+}
@@ -0,0 +1,83 @@
+package usecases
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/Ahimta/tweeters-stats-golang/services"
+)
+
+// HeatmapResult blablabla
+type HeatmapResult struct {
+	Heatmaps []*entities.TweeterHeatmap
+	Total    entities.Heatmap
+
+	PagesCount  uint
+	TweetsCount uint
+}
+
+// Heatmap counts tweets by weekday and hour of day in location, for the whole
+// timeline and for each tweeter (busiest first)
+func Heatmap(
+	ctx context.Context,
+	tweetsService services.TweetsService,
+	accessToken,
+	accessSecret string,
+	window TimeWindow,
+	location *time.Location,
+) (
+	*HeatmapResult, error,
+) {
+
+	timeline, err := fetchTimeline(ctx, tweetsService, accessToken, accessSecret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := &HeatmapResult{
+		PagesCount:  timeline.PagesCount,
+		TweetsCount: timeline.TweetsCount,
+	}
+	heatmapsByUsername := make(map[string]*entities.TweeterHeatmap)
+
+	for _, tweeter := range timeline.Tweeters {
+		if tweeter.CreatedAt.IsZero() || !window.Contains(tweeter.CreatedAt) {
+			continue
+		}
+
+		createdAt := tweeter.CreatedAt.In(location)
+		heatmap, ok := heatmapsByUsername[tweeter.Username]
+
+		if !ok {
+			heatmap = &entities.TweeterHeatmap{
+				FullName: tweeter.FullName,
+				Username: tweeter.Username,
+			}
+			heatmapsByUsername[tweeter.Username] = heatmap
+		}
+
+		heatmap.TweetsCount++
+		heatmap.Heatmap.Count(createdAt)
+		result.Total.Count(createdAt)
+	}
+
+	result.Heatmaps = make([]*entities.TweeterHeatmap, 0, len(heatmapsByUsername))
+
+	for _, heatmap := range heatmapsByUsername {
+		result.Heatmaps = append(result.Heatmaps, heatmap)
+	}
+
+	heatmaps := result.Heatmaps
+	sortRanking(
+		heatmaps,
+		StatsQuery{},
+		func(i, j int) int {
+			return compareCounts(heatmaps[i].TweetsCount, heatmaps[j].TweetsCount)
+		},
+		func(i int) string { return heatmaps[i].Username },
+	)
+
+	return result, nil
+}
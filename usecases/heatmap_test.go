@@ -0,0 +1,97 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+)
+
+func TestHeatmap(t *testing.T) {
+	ctx := context.Background()
+
+	// a Saturday
+	at := func(hour int) time.Time {
+		return time.Date(2018, 9, 1, hour, 30, 0, 0, time.UTC)
+	}
+
+	service := &tweetsService{
+		tweeters: []*entities.Tweeter{
+			{Username: "jsmith", CreatedAt: at(23)},
+			{Username: "jsmith", CreatedAt: at(23)},
+			{Username: "jdoe", CreatedAt: at(12)},
+			{Username: "nobody"},
+		},
+	}
+
+	//
+	result, err := Heatmap(ctx, service, "blablabla", "blablabla", TimeWindow{}, time.UTC)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Total[time.Saturday][23] != 2 || result.Total[time.Saturday][12] != 1 {
+		t.Errorf("Should count the whole timeline by weekday and hour: %v", result.Total)
+	}
+
+	if len(result.Heatmaps) != 2 ||
+		result.Heatmaps[0].Username != "jsmith" ||
+		result.Heatmaps[0].TweetsCount != 2 ||
+		result.Heatmaps[0].Heatmap[time.Saturday][23] != 2 ||
+		result.Heatmaps[1].Heatmap[time.Saturday][12] != 1 {
+		t.Errorf("Should count every tweeter, busiest first: %v", result.Heatmaps)
+	}
+
+	if result.PagesCount != 1 || result.TweetsCount != 4 {
+		t.Errorf("Should return the pages and tweets consumed by TweetService")
+	}
+
+	//
+	riyadh := time.FixedZone("AST", 3*60*60)
+	result, err = Heatmap(ctx, service, "blablabla", "blablabla", TimeWindow{}, riyadh)
+
+	if err != nil ||
+		result.Total[time.Sunday][2] != 2 ||
+		result.Total[time.Saturday][15] != 1 {
+		t.Errorf("Should count in the given location: %v, %v", result, err)
+	}
+
+	//
+	result, err = Heatmap(
+		ctx,
+		&tweetsService{tweeters: []*entities.Tweeter{
+			{Username: "b", CreatedAt: at(1)},
+			{Username: "c", CreatedAt: at(1)},
+			{Username: "a", CreatedAt: at(1)},
+		}},
+		"blablabla",
+		"blablabla",
+		TimeWindow{},
+		time.UTC,
+	)
+
+	if err != nil ||
+		len(result.Heatmaps) != 3 ||
+		result.Heatmaps[0].Username != "a" ||
+		result.Heatmaps[1].Username != "b" ||
+		result.Heatmaps[2].Username != "c" {
+		t.Errorf("Should break ties by username: %v, %v", result, err)
+	}
+
+	//
+	_, err = Heatmap(
+		ctx,
+		&tweetsService{err: errors.New("Whaaat!")},
+		"blablabla",
+		"blablabla",
+		TimeWindow{},
+		time.UTC,
+	)
+
+	if err == nil {
+		t.Errorf("Should return TweetsService errors")
+	}
+}
@@ -0,0 +1,29 @@
+package usecases
+
+import (
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/Ahimta/tweeters-stats-golang/services"
+)
+
+// RateLimit returns the last known rate limit of accessToken without calling
+// Twitter, it's nil when unknown (e.g., before the first stats request)
+func RateLimit(
+	tweetsService services.TweetsService,
+	accessToken,
+	accessSecret string,
+) (*entities.RateLimit, error) {
+
+	if accessToken == "" || accessSecret == "" {
+		return nil, &entities.UnauthenticatedError{
+			Reason: "accessToken or accessSecret missing -_-",
+		}
+	}
+
+	rateLimit, ok := tweetsService.RateLimit(accessToken)
+
+	if !ok {
+		return nil, nil
+	}
+
+	return &rateLimit, nil
+}
@@ -0,0 +1,41 @@
+package usecases
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+)
+
+func TestRateLimit(t *testing.T) {
+	//
+	rateLimit := &entities.RateLimit{
+		Limit:     15,
+		Remaining: 3,
+		Reset:     time.Date(2018, 9, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	result, err := RateLimit(
+		&tweetsService{rateLimit: rateLimit},
+		"accessToken",
+		"accessSecret",
+	)
+
+	if err != nil || *result != *rateLimit {
+		t.Errorf("Should return the service's rate limit: %v, %v", result, err)
+	}
+
+	//
+	result, err = RateLimit(&tweetsService{}, "accessToken", "accessSecret")
+
+	if err != nil || result != nil {
+		t.Errorf("Should return nil when the rate limit is unknown")
+	}
+
+	//
+	result, err = RateLimit(&tweetsService{}, "accessToken", "")
+
+	if _, ok := err.(*entities.UnauthenticatedError); !ok || result != nil {
+		t.Errorf("Whaaat!")
+	}
+}
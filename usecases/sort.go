@@ -0,0 +1,157 @@
+package usecases
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+)
+
+// StatsSortKey is what tweeters stats are sorted by
+type StatsSortKey string
+
+// tweeters with equal keys are always sorted by username
+const (
+	SortByCount    StatsSortKey = "count"
+	SortByUsername StatsSortKey = "username"
+	SortByFullName StatsSortKey = "fullName"
+	SortByRetweets StatsSortKey = "retweets"
+	SortByReplies  StatsSortKey = "replies"
+)
+
+// StatsQuery sorts and pages tweeters stats, its zero value sorts by count
+// (descending) and returns every tweeter
+type StatsQuery struct {
+	SortBy    StatsSortKey
+	Ascending bool
+
+	// a zero Limit means no limit
+	Limit  int
+	Offset int
+}
+
+// ParseStatsQuery validates the sort and order query parameters, an empty
+// order is descending for counts and ascending for names
+func ParseStatsQuery(sortBy, order string, limit, offset int) (
+	StatsQuery, error,
+) {
+
+	query := StatsQuery{SortBy: StatsSortKey(sortBy), Limit: limit, Offset: offset}
+
+	switch query.SortBy {
+	case "":
+		query.SortBy = SortByCount
+	case SortByCount, SortByRetweets, SortByReplies:
+	case SortByUsername, SortByFullName:
+		query.Ascending = true
+	default:
+		return StatsQuery{}, &entities.InvalidInputError{
+			Message: fmt.Sprintf(
+				"sort must be count, username, fullName, retweets or replies, not %q",
+				sortBy,
+			),
+		}
+	}
+
+	switch order {
+	case "":
+	case "asc":
+		query.Ascending = true
+	case "desc":
+		query.Ascending = false
+	default:
+		return StatsQuery{}, &entities.InvalidInputError{
+			Message: fmt.Sprintf("order must be asc or desc, not %q", order),
+		}
+	}
+
+	if limit < 0 || offset < 0 {
+		return StatsQuery{}, &entities.InvalidInputError{
+			Message: "limit and offset must be non-negative",
+		}
+	}
+
+	return query, nil
+}
+
+// compareStats returns a negative number when x comes before y in ascending
+// order, zero when they're equal and a positive number otherwise
+func compareStats(x, y *entities.TweeterStats, sortBy StatsSortKey) int {
+	switch sortBy {
+	case SortByUsername:
+		return strings.Compare(strings.ToLower(x.Username), strings.ToLower(y.Username))
+	case SortByFullName:
+		return strings.Compare(strings.ToLower(x.FullName), strings.ToLower(y.FullName))
+	case SortByRetweets:
+		return compareCounts(x.RetweetCount, y.RetweetCount)
+	case SortByReplies:
+		return compareCounts(x.ReplyCount, y.ReplyCount)
+	default:
+		return compareCounts(x.TweetsCount, y.TweetsCount)
+	}
+}
+
+func compareCounts(x, y uint) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// sortStats breaks ties by username (ascending, whatever the order) so
+// equal stats always come back in the same order
+func sortStats(stats []*entities.TweeterStats, query StatsQuery) {
+	sortRanking(
+		stats,
+		query,
+		func(i, j int) int { return compareStats(stats[i], stats[j], query.SortBy) },
+		func(i int) string { return stats[i].Username },
+	)
+}
+
+func pageStats(
+	stats []*entities.TweeterStats,
+	query StatsQuery,
+) []*entities.TweeterStats {
+
+	start, end := pageRange(len(stats), query)
+	return stats[start:end]
+}
+
+// sortRanking sorts any ranking (e.g., tweeters or hashtags) in query's order
+// by compare, ties are broken by name (ascending, whatever the order)
+func sortRanking(
+	ranking interface{},
+	query StatsQuery,
+	compare func(i, j int) int,
+	name func(i int) string,
+) {
+
+	sort.Slice(ranking, func(i, j int) bool {
+		if c := compare(i, j); c != 0 {
+			return (c < 0) == query.Ascending
+		}
+
+		return name(i) < name(j)
+	})
+}
+
+// pageRange is the [start, end) of query's page in a ranking of n items
+func pageRange(n int, query StatsQuery) (start, end int) {
+	if query.Offset >= n {
+		return n, n
+	}
+
+	start, end = query.Offset, n
+
+	if query.Limit > 0 && query.Limit < end-start {
+		end = start + query.Limit
+	}
+
+	return start, end
+}
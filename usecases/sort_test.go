@@ -0,0 +1,104 @@
+package usecases
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+)
+
+func TestParseStatsQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		sortBy  string
+		order   string
+		want    StatsQuery
+		wantErr bool
+	}{
+		{"should sort by count by default", "", "", StatsQuery{SortBy: SortByCount}, false},
+		{"should sort counts descending", "retweets", "", StatsQuery{SortBy: SortByRetweets}, false},
+		{"should sort names ascending", "fullName", "", StatsQuery{SortBy: SortByFullName, Ascending: true}, false},
+		{"should respect order", "username", "desc", StatsQuery{SortBy: SortByUsername}, false},
+		{"should respect order", "count", "asc", StatsQuery{SortBy: SortByCount, Ascending: true}, false},
+		{"should reject unknown keys", "followers", "", StatsQuery{}, true},
+		{"should reject unknown orders", "count", "up", StatsQuery{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStatsQuery(tt.sortBy, tt.order, 0, 0)
+
+			if _, ok := err.(*entities.InvalidInputError); ok != tt.wantErr {
+				t.Errorf("ParseStatsQuery() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseStatsQuery() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	//
+	if _, err := ParseStatsQuery("", "", -1, 0); err == nil {
+		t.Errorf("Should reject negative limits")
+	}
+}
+
+func TestTweetersStats_query(t *testing.T) {
+	ctx := context.Background()
+
+	service := &tweetsService{
+		tweeters: []*entities.Tweeter{
+			{Username: "c", FullName: "Alice", Type: entities.Reply},
+			{Username: "b", FullName: "Bob", Type: entities.Retweet},
+			{Username: "a", FullName: "Carol", Type: entities.OriginalTweet},
+			{Username: "d", FullName: "Dave", Type: entities.Retweet},
+			{Username: "d", FullName: "Dave", Type: entities.Retweet},
+		},
+	}
+
+	usernames := func(query StatsQuery) []string {
+		result, err := TweetersStats(
+			ctx,
+			service,
+			"blablabla",
+			"blablabla",
+			TimeWindow{},
+			query,
+		)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result.TotalCount != 4 {
+			t.Errorf("Should count every tweeter: %v", result.TotalCount)
+		}
+
+		names := []string{}
+		for _, stats := range result.Stats {
+			names = append(names, stats.Username)
+		}
+
+		return names
+	}
+
+	tests := []struct {
+		query StatsQuery
+		want  []string
+	}{
+		{StatsQuery{}, []string{"d", "a", "b", "c"}},
+		{StatsQuery{Ascending: true}, []string{"a", "b", "c", "d"}},
+		{StatsQuery{SortBy: SortByFullName, Ascending: true}, []string{"c", "b", "a", "d"}},
+		{StatsQuery{SortBy: SortByRetweets}, []string{"d", "b", "a", "c"}},
+		{StatsQuery{SortBy: SortByReplies}, []string{"c", "a", "b", "d"}},
+		{StatsQuery{Limit: 2, Offset: 1}, []string{"a", "b"}},
+		{StatsQuery{Offset: 10}, []string{}},
+	}
+	for _, tt := range tests {
+		if got := usernames(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("TweetersStats(ctx, %v) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
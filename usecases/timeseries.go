@@ -0,0 +1,151 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/Ahimta/tweeters-stats-golang/services"
+)
+
+// TimeBucket is the period tweets are counted per in a time series
+type TimeBucket string
+
+// buckets start at the beginning of a UTC hour or day
+const (
+	HourBucket TimeBucket = "hour"
+	DayBucket  TimeBucket = "day"
+)
+
+// ParseTimeBucket blablabla
+func ParseTimeBucket(s string) (TimeBucket, error) {
+	switch bucket := TimeBucket(s); bucket {
+	case HourBucket, DayBucket:
+		return bucket, nil
+	default:
+		return "", &entities.InvalidInputError{
+			Message: fmt.Sprintf("bucket must be hour or day, not %q", s),
+		}
+	}
+}
+
+// Start returns the start of the bucket t falls in
+func (bucket TimeBucket) Start(t time.Time) time.Time {
+	t = t.UTC()
+
+	if bucket == HourBucket {
+		return t.Truncate(time.Hour)
+	}
+
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// TimeSeriesQuery blablabla
+type TimeSeriesQuery struct {
+	Bucket TimeBucket
+
+	// only this tweeter's series is returned when set (the total still covers
+	// the whole timeline)
+	Username string
+}
+
+// TimeSeriesResult blablabla
+type TimeSeriesResult struct {
+	Series []*entities.TweeterTimeSeries
+	Total  []entities.TimeSeriesPoint
+
+	PagesCount  uint
+	TweetsCount uint
+}
+
+// TimeSeries counts each tweeter's tweets (and the whole timeline's) per
+// bucket, buckets without tweets are left out and points are oldest first
+func TimeSeries(
+	ctx context.Context,
+	tweetsService services.TweetsService,
+	accessToken,
+	accessSecret string,
+	window TimeWindow,
+	query TimeSeriesQuery,
+) (
+	*TimeSeriesResult, error,
+) {
+
+	timeline, err := fetchTimeline(ctx, tweetsService, accessToken, accessSecret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	total := make(map[time.Time]uint)
+	countsByUsername := make(map[string]map[time.Time]uint)
+	seriesByUsername := make(map[string]*entities.TweeterTimeSeries)
+
+	for _, tweeter := range timeline.Tweeters {
+		if tweeter.CreatedAt.IsZero() || !window.Contains(tweeter.CreatedAt) {
+			continue
+		}
+
+		start := query.Bucket.Start(tweeter.CreatedAt)
+		total[start]++
+
+		if query.Username != "" &&
+			!strings.EqualFold(tweeter.Username, query.Username) {
+			continue
+		}
+
+		series, ok := seriesByUsername[tweeter.Username]
+
+		if !ok {
+			series = &entities.TweeterTimeSeries{
+				FullName: tweeter.FullName,
+				Username: tweeter.Username,
+			}
+			seriesByUsername[tweeter.Username] = series
+			countsByUsername[tweeter.Username] = make(map[time.Time]uint)
+		}
+
+		series.TweetsCount++
+		countsByUsername[tweeter.Username][start]++
+	}
+
+	series := make([]*entities.TweeterTimeSeries, 0, len(seriesByUsername))
+
+	for username, tweeterSeries := range seriesByUsername {
+		tweeterSeries.Points = timeSeriesPoints(countsByUsername[username])
+		series = append(series, tweeterSeries)
+	}
+
+	sortRanking(
+		series,
+		StatsQuery{},
+		func(i, j int) int {
+			return compareCounts(series[i].TweetsCount, series[j].TweetsCount)
+		},
+		func(i int) string { return series[i].Username },
+	)
+
+	return &TimeSeriesResult{
+		Series:      series,
+		Total:       timeSeriesPoints(total),
+		PagesCount:  timeline.PagesCount,
+		TweetsCount: timeline.TweetsCount,
+	}, nil
+}
+
+func timeSeriesPoints(counts map[time.Time]uint) []entities.TimeSeriesPoint {
+	points := make([]entities.TimeSeriesPoint, 0, len(counts))
+
+	for start, count := range counts {
+		points = append(points, entities.TimeSeriesPoint{Time: start, Count: count})
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Time.Before(points[j].Time)
+	})
+
+	return points
+}
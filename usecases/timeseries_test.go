@@ -0,0 +1,147 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Ahimta/tweeters-stats-golang/entities"
+)
+
+func TestTimeSeries(t *testing.T) {
+	ctx := context.Background()
+
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2018, 9, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	service := &tweetsService{
+		tweeters: []*entities.Tweeter{
+			{Username: "jsmith", FullName: "John Smith", CreatedAt: at(1, 12, 5)},
+			{Username: "jsmith", FullName: "John Smith", CreatedAt: at(1, 12, 55)},
+			{Username: "jsmith", FullName: "John Smith", CreatedAt: at(2, 9, 0)},
+			{Username: "jdoe", FullName: "Jane Doe", CreatedAt: at(1, 13, 0)},
+			{Username: "nobody"},
+		},
+	}
+
+	//
+	result, err := TimeSeries(
+		ctx,
+		service,
+		"blablabla",
+		"blablabla",
+		TimeWindow{},
+		TimeSeriesQuery{Bucket: HourBucket},
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantTotal := []entities.TimeSeriesPoint{
+		{Time: at(1, 12, 0), Count: 2},
+		{Time: at(1, 13, 0), Count: 1},
+		{Time: at(2, 9, 0), Count: 1},
+	}
+
+	if !reflect.DeepEqual(result.Total, wantTotal) {
+		t.Errorf("Should count the whole timeline per hour: %v", result.Total)
+	}
+
+	if len(result.Series) != 2 ||
+		result.Series[0].Username != "jsmith" ||
+		result.Series[0].TweetsCount != 3 ||
+		len(result.Series[0].Points) != 2 ||
+		result.Series[1].Username != "jdoe" {
+		t.Errorf("Should count every tweeter per hour, busiest first: %v", result.Series)
+	}
+
+	if result.PagesCount != 1 || result.TweetsCount != 5 {
+		t.Errorf("Should return the pages and tweets consumed by TweetService")
+	}
+
+	//
+	result, err = TimeSeries(
+		ctx,
+		service,
+		"blablabla",
+		"blablabla",
+		TimeWindow{Until: at(2, 0, 0)},
+		TimeSeriesQuery{Bucket: DayBucket, Username: "JSmith"},
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantSeries := []*entities.TweeterTimeSeries{{
+		FullName:    "John Smith",
+		Username:    "jsmith",
+		TweetsCount: 2,
+		Points:      []entities.TimeSeriesPoint{{Time: at(1, 0, 0), Count: 2}},
+	}}
+
+	if !reflect.DeepEqual(result.Series, wantSeries) {
+		t.Errorf("Should only return the requested tweeter: %v", result.Series)
+	}
+
+	if !reflect.DeepEqual(
+		result.Total,
+		[]entities.TimeSeriesPoint{{Time: at(1, 0, 0), Count: 3}},
+	) {
+		t.Errorf("Should count the whole timeline per day: %v", result.Total)
+	}
+
+	//
+	result, err = TimeSeries(
+		ctx,
+		&tweetsService{tweeters: []*entities.Tweeter{
+			{Username: "b", CreatedAt: at(1, 0, 0)},
+			{Username: "c", CreatedAt: at(1, 0, 0)},
+			{Username: "a", CreatedAt: at(1, 0, 0)},
+		}},
+		"blablabla",
+		"blablabla",
+		TimeWindow{},
+		TimeSeriesQuery{Bucket: DayBucket},
+	)
+
+	if err != nil ||
+		len(result.Series) != 3 ||
+		result.Series[0].Username != "a" ||
+		result.Series[1].Username != "b" ||
+		result.Series[2].Username != "c" {
+		t.Errorf("Should break ties by username: %v, %v", result, err)
+	}
+
+	//
+	_, err = TimeSeries(
+		ctx,
+		&tweetsService{err: errors.New("Whaaat!")},
+		"blablabla",
+		"blablabla",
+		TimeWindow{},
+		TimeSeriesQuery{Bucket: DayBucket},
+	)
+
+	if err == nil {
+		t.Errorf("Should return TweetsService errors")
+	}
+}
+
+func TestParseTimeBucket(t *testing.T) {
+	if bucket, err := ParseTimeBucket("hour"); err != nil || bucket != HourBucket {
+		t.Errorf("Whaaat!")
+	}
+
+	if bucket, err := ParseTimeBucket("day"); err != nil || bucket != DayBucket {
+		t.Errorf("Whaaat!")
+	}
+
+	if _, err := ParseTimeBucket("week"); err == nil {
+		t.Errorf("Should return an error for unknown buckets")
+	}
+}
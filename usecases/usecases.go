@@ -1,20 +1,37 @@
 package usecases
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/url"
-	"sort"
+	"time"
 
 	"github.com/Ahimta/tweeters-stats-golang/auth"
 	"github.com/Ahimta/tweeters-stats-golang/entities"
 	"github.com/Ahimta/tweeters-stats-golang/services"
+	"github.com/Ahimta/tweeters-stats-golang/sessions"
+)
+
+// LoginTTL is how long users have to authorize the app on Twitter
+const LoginTTL = 10 * time.Minute
+
+// reasons for Oauth1Callback to reject a callback
+var (
+	ErrLoginMissing  = errors.New("usecases: no login in progress")
+	ErrLoginMismatch = errors.New("usecases: callback doesn't match the login")
+	ErrLoginExpired  = errors.New("usecases: login expired")
+	ErrLoginReplayed = errors.New("usecases: login already completed")
+	ErrLoginDenied   = errors.New("usecases: authorization denied on Twitter")
 )
 
 // LoginResult blablabla
 type LoginResult struct {
 	AuthorizationURL *url.URL
-	RequestSecret    string
+
+	// the browser has to send RequestToken back (e.g., in a cookie) so the
+	// callback can be matched to this login
+	RequestToken string
 }
 
 // Oauth1CallbackResult blablabla
@@ -23,95 +40,232 @@ type Oauth1CallbackResult struct {
 	AccessSecret string
 }
 
-type statsSort []*entities.TweeterStats
+// TweetersStatsResult blablabla
+type TweetersStatsResult struct {
+	Stats []*entities.TweeterStats
+
+	// how many tweeters there are before StatsQuery's limit and offset
+	TotalCount uint
+	Summary    *entities.StatsSummary
 
-func (xs statsSort) Len() int {
-	return len(xs)
+	PagesCount  uint
+	TweetsCount uint
+	RateLimit   *entities.RateLimit
+	Truncated   bool
+	FetchedAt   time.Time
 }
 
-func (xs statsSort) Swap(i, j int) {
-	xs[i], xs[j] = xs[j], xs[i]
+// TimeWindow restricts stats to tweets created in [Since, Until), a zero
+// bound means the window is open on that side
+type TimeWindow struct {
+	Since time.Time
+	Until time.Time
 }
 
-func (xs statsSort) Less(i, j int) bool {
-	return (xs[i].TweetsCount < xs[j].TweetsCount)
+// Contains blablabla
+func (window TimeWindow) Contains(t time.Time) bool {
+	if window.Since.IsZero() && window.Until.IsZero() {
+		return true
+	}
+
+	if t.IsZero() {
+		return false
+	}
+
+	return !t.Before(window.Since) &&
+		(window.Until.IsZero() || t.Before(window.Until))
 }
 
 // TweetersStats blablabla
 func TweetersStats(
+	ctx context.Context,
 	tweetsService services.TweetsService,
 	accessToken,
 	accessSecret string,
+	window TimeWindow,
+	query StatsQuery,
 ) (
-	[]*entities.TweeterStats, error,
+	*TweetersStatsResult, error,
 ) {
 
-	if accessToken == "" || accessSecret == "" {
-		return nil, errors.New("usecases: accessToken or accessSecret missing -_-")
+	timeline, err := fetchTimeline(ctx, tweetsService, accessToken, accessSecret)
+
+	if err != nil {
+		return nil, err
 	}
 
-	tweeters, err := tweetsService.Tweeters(accessToken, accessSecret)
+	return tweetersStats(timeline, window, query), nil
+}
+
+// StreamTweetersStats is like TweetersStats but also calls progress with the
+// stats of the tweets fetched so far after each timeline page
+func StreamTweetersStats(
+	ctx context.Context,
+	tweetsService services.TweetsService,
+	accessToken,
+	accessSecret string,
+	window TimeWindow,
+	query StatsQuery,
+	progress func(partial *TweetersStatsResult),
+) (
+	*TweetersStatsResult, error,
+) {
+
+	reporting := services.WithProgress(
+		tweetsService,
+		func(timeline *entities.Timeline) {
+			progress(tweetersStats(timeline, window, query))
+		},
+	)
+
+	return TweetersStats(ctx, reporting, accessToken, accessSecret, window, query)
+}
+
+// ArchiveTweetersStats is like TweetersStats but for services that don't need
+// an access token (e.g., a Twitter archive)
+func ArchiveTweetersStats(
+	ctx context.Context,
+	tweetsService services.TweetsService,
+	window TimeWindow,
+) (
+	*TweetersStatsResult, error,
+) {
+
+	timeline, err := tweetsService.Tweeters(ctx, "", "")
 
 	if err != nil {
 		return nil, err
 	}
 
+	return tweetersStats(timeline, window, StatsQuery{}), nil
+}
+
+func tweetersStats(
+	timeline *entities.Timeline,
+	window TimeWindow,
+	query StatsQuery,
+) *TweetersStatsResult {
+
 	statsByUsername := make(map[string]*entities.TweeterStats)
-	for _, tweeter := range tweeters {
+	for _, tweeter := range timeline.Tweeters {
+		if !window.Contains(tweeter.CreatedAt) {
+			continue
+		}
+
 		tweeterStats, ok := statsByUsername[tweeter.Username]
 
-		if ok {
-			tweeterStats.TweetsCount++
-		} else {
-			statsByUsername[tweeter.Username] = &entities.TweeterStats{
-				FullName:    tweeter.FullName,
-				Username:    tweeter.Username,
-				TweetsCount: 1,
+		if !ok {
+			tweeterStats = &entities.TweeterStats{
+				FullName: tweeter.FullName,
+				Username: tweeter.Username,
 			}
+			statsByUsername[tweeter.Username] = tweeterStats
 		}
+
+		tweeterStats.Count(tweeter.Type)
 	}
 
 	tweetersStats := make([]*entities.TweeterStats, 0, len(statsByUsername))
 
 	for _, tweeterStats := range statsByUsername {
-		tweetersStats = append(tweetersStats, &entities.TweeterStats{
-			FullName:    tweeterStats.FullName,
-			Username:    tweeterStats.Username,
-			TweetsCount: tweeterStats.TweetsCount,
-		})
+		tweetersStats = append(tweetersStats, tweeterStats)
+	}
+
+	summary := summarizeStats(tweetersStats)
+
+	sortStats(tweetersStats, query)
+	return &TweetersStatsResult{
+		Stats:       pageStats(tweetersStats, query),
+		TotalCount:  uint(len(tweetersStats)),
+		Summary:     summary,
+		PagesCount:  timeline.PagesCount,
+		TweetsCount: timeline.TweetsCount,
+		RateLimit:   timeline.RateLimit,
+		Truncated:   timeline.Truncated,
+		FetchedAt:   timeline.FetchedAt,
 	}
+}
+
+func fetchTimeline(
+	ctx context.Context,
+	tweetsService services.TweetsService,
+	accessToken,
+	accessSecret string,
+) (
+	*entities.Timeline, error,
+) {
 
-	sort.Sort(sort.Reverse(statsSort(tweetersStats)))
-	return tweetersStats, nil
+	if accessToken == "" || accessSecret == "" {
+		return nil, &entities.UnauthenticatedError{
+			Reason: "accessToken or accessSecret missing -_-",
+		}
+	}
+
+	return tweetsService.Tweeters(ctx, accessToken, accessSecret)
 }
 
-// Oauth1Callback blablabla
+// Oauth1Callback exchanges the request token of a callback for an access
+// token, requestToken is the one the browser got from Login
 func Oauth1Callback(
+	ctx context.Context,
 	client auth.Oauth1Client,
-	requestSecret string,
+	requestTokens sessions.RequestTokenStore,
+	requestToken string,
 	r *http.Request,
 ) (
 	*Oauth1CallbackResult, error,
 ) {
 
-	if requestSecret == "" || r == nil {
-		return nil, errors.New("usecases: requestSecret or request missing -_-")
+	if r == nil {
+		return nil, errors.New("usecases: request missing -_-")
+	}
+
+	if r.URL != nil && r.URL.Query().Get("denied") != "" {
+		if r.URL.Query().Get("denied") == requestToken {
+			requestTokens.Take(requestToken)
+		}
+
+		return nil, ErrLoginDenied
+	}
+
+	if requestToken == "" {
+		return nil, ErrLoginMissing
+	}
+
+	callbackToken, verifier, err := client.ParseAuthorizationCallback(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if callbackToken != requestToken {
+		return nil, ErrLoginMismatch
 	}
 
-	requestToken, verifier, err := client.ParseAuthorizationCallback(r)
+	requestSecret, err := requestTokens.Take(requestToken)
+
+	switch err {
+	case sessions.ErrNotFound:
+		return nil, ErrLoginMissing
+	case sessions.ErrExpired:
+		return nil, ErrLoginExpired
+	case sessions.ErrUsed:
+		return nil, ErrLoginReplayed
+	}
 
 	if err != nil {
 		return nil, err
 	}
 
 	accessToken, accessSecret, err := client.AccessToken(
+		ctx,
 		requestToken,
 		requestSecret,
 		verifier,
 	)
 
 	if err != nil {
-		return nil, err
+		return nil, &entities.UpstreamError{Err: err}
 	}
 
 	return &Oauth1CallbackResult{
@@ -120,17 +274,31 @@ func Oauth1Callback(
 	}, nil
 }
 
-// Login blablabla
-func Login(client auth.Oauth1Client) (*LoginResult, error) {
-	requestToken, requestSecret, err := client.RequestToken()
+// Login keeps the request secret server-side in requestTokens
+func Login(
+	ctx context.Context,
+	client auth.Oauth1Client,
+	requestTokens sessions.RequestTokenStore,
+) (*LoginResult, error) {
+
+	requestToken, requestSecret, err := client.RequestToken(ctx)
 
 	if err != nil {
-		return nil, err
+		return nil, &entities.UpstreamError{Err: err}
 	}
 
 	authorizationURL, err := client.AuthorizationURL(requestToken)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := requestTokens.Save(requestToken, requestSecret); err != nil {
+		return nil, err
+	}
+
 	return &LoginResult{
 		AuthorizationURL: authorizationURL,
-		RequestSecret:    requestSecret,
+		RequestToken:     requestToken,
 	}, nil
 }
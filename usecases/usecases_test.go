@@ -1,12 +1,15 @@
 package usecases
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/Ahimta/tweeters-stats-golang/entities"
+	"github.com/Ahimta/tweeters-stats-golang/sessions"
 )
 
 type oauthClient struct {
@@ -34,27 +37,54 @@ type oauthClient struct {
 }
 
 type tweetsService struct {
-	tweeters []*entities.Tweeter
-	err      error
+	tweeters  []*entities.Tweeter
+	rateLimit *entities.RateLimit
+	err       error
+}
+
+func (service *tweetsService) RateLimit(accessToken string) (
+	entities.RateLimit, bool,
+) {
+
+	if service.rateLimit == nil {
+		return entities.RateLimit{}, false
+	}
+
+	return *service.rateLimit, true
 }
 
 func (service *tweetsService) Tweeters(
+	ctx context.Context,
 	accessToken,
 	accessSecret string,
 ) (
-	[]*entities.Tweeter, error,
+	*entities.Timeline, error,
 ) {
 
-	return service.tweeters, service.err
+	if service.err != nil {
+		return nil, service.err
+	}
+
+	return &entities.Timeline{
+		Tweeters:    service.tweeters,
+		PagesCount:  1,
+		TweetsCount: uint(len(service.tweeters)),
+		RateLimit:   service.rateLimit,
+	}, nil
 }
 
 func (client *oauthClient) AccessToken(
+	ctx context.Context,
 	requestToken,
 	requestSecret,
 	verifier string) (
 	accessToken, accessSecret string, err error,
 ) {
 
+	if err := ctx.Err(); err != nil {
+		return "", "", err
+	}
+
 	return client.accessToken, client.accessSecret, client.accessTokenErr
 }
 
@@ -65,17 +95,25 @@ func (client *oauthClient) AuthorizationURL(requestToken string) (
 	return client.url, client.authorizationURLError
 }
 
-func (client *oauthClient) HTTPClient(accessToken, accessSecret string) (
+func (client *oauthClient) HTTPClient(
+	ctx context.Context,
+	accessToken,
+	accessSecret string,
+) (
 	*http.Client, error,
 ) {
 
 	return client.client, nil
 }
 
-func (client *oauthClient) RequestToken() (
+func (client *oauthClient) RequestToken(ctx context.Context) (
 	requestToken, requestSecret string, err error,
 ) {
 
+	if err := ctx.Err(); err != nil {
+		return "", "", err
+	}
+
 	return client.requestToken, client.requestSecret, client.requestTokenError
 }
 
@@ -89,37 +127,51 @@ func (client *oauthClient) ParseAuthorizationCallback(r *http.Request) (
 }
 
 func TestTweetersStats(t *testing.T) {
+	ctx := context.Background()
+
 	//
-	stats, err := TweetersStats(
+	result, err := TweetersStats(
+		ctx,
 		&tweetsService{
 			tweeters: []*entities.Tweeter{
 				&entities.Tweeter{
 					FullName: "John Smith1",
 					Username: "jsmith1",
+					Type:     entities.OriginalTweet,
 				},
 				&entities.Tweeter{
 					FullName: "John Smith0",
 					Username: "jsmith0",
+					Type:     entities.Retweet,
 				},
 				&entities.Tweeter{
 					FullName: "John Smith2",
 					Username: "jsmith2",
+					Type:     entities.Quote,
 				},
 				&entities.Tweeter{
 					FullName: "John Smith0",
 					Username: "jsmith0",
+					Type:     entities.Reply,
 				},
 			},
 			err: nil,
 		},
 		"blablabla",
 		"blablabla",
+		TimeWindow{},
+		StatsQuery{},
 	)
 
 	if err != nil {
-		t.Errorf(err.Error())
+		t.Error(err)
+	}
+
+	if result.PagesCount != 1 || result.TweetsCount != 4 {
+		t.Errorf("Should return the pages and tweets consumed by TweetService")
 	}
 
+	stats := result.Stats
 	if stats[0].FullName != "John Smith0" ||
 		stats[0].Username != "jsmith0" ||
 		stats[0].TweetsCount != 2 {
@@ -127,83 +179,205 @@ func TestTweetersStats(t *testing.T) {
 		t.Errorf("Should return stats related to tweeters from TweetService")
 	}
 
+	if stats[0].RetweetCount != 1 ||
+		stats[0].ReplyCount != 1 ||
+		stats[0].OriginalCount != 0 ||
+		stats[0].QuoteCount != 0 {
+
+		t.Errorf("Should break down tweets count by tweet type")
+	}
+
 	if len(stats) != 3 {
 		t.Errorf("Whaaaat!")
 	}
 
 	//
-	stats, err = TweetersStats(
+	result, err = TweetersStats(
+		ctx,
 		&tweetsService{
 			tweeters: nil,
 			err:      nil,
 		},
 		"blablabla",
 		"",
+		TimeWindow{},
+		StatsQuery{},
 	)
 
 	if err == nil {
 		t.Errorf("Should return an error when a parameter is missing")
 	}
 
-	if len(stats) != 0 {
+	if result != nil {
 		t.Errorf("Whaaaat!")
 	}
 
 	//
-	stats, err = TweetersStats(
+	result, err = TweetersStats(
+		ctx,
 		&tweetsService{
 			tweeters: nil,
 			err:      nil,
 		},
 		"blablabla",
 		"",
+		TimeWindow{},
+		StatsQuery{},
 	)
 
 	if err == nil {
 		t.Errorf("Should return an error when a parameter is missing")
 	}
 
-	if len(stats) != 0 {
+	if result != nil {
 		t.Errorf("Whaaaat!")
 	}
 
 	//
-	stats, err = TweetersStats(
+	result, err = TweetersStats(
+		ctx,
 		&tweetsService{
 			tweeters: nil,
 			err:      errors.New("blablabla"),
 		},
 		"blablabla",
 		"blabla",
+		TimeWindow{},
+		StatsQuery{},
 	)
 
 	if !(err != nil) {
 		t.Errorf("Should return an error when TweetService returns an error")
 	}
 
-	if len(stats) != 0 {
+	if result != nil {
 		t.Errorf("Whaaaat!")
 	}
+
+	//
+	now := time.Now()
+	result, err = TweetersStats(
+		ctx,
+		&tweetsService{
+			tweeters: []*entities.Tweeter{
+				&entities.Tweeter{
+					FullName:  "John Smith0",
+					Username:  "jsmith0",
+					CreatedAt: now.Add(-2 * time.Hour),
+				},
+				&entities.Tweeter{
+					FullName:  "John Smith1",
+					Username:  "jsmith1",
+					CreatedAt: now.Add(-30 * time.Minute),
+				},
+				&entities.Tweeter{
+					FullName: "John Smith2",
+					Username: "jsmith2",
+				},
+			},
+			err: nil,
+		},
+		"blablabla",
+		"blablabla",
+		TimeWindow{Since: now.Add(-time.Hour)},
+		StatsQuery{},
+	)
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(result.Stats) != 1 || result.Stats[0].Username != "jsmith1" {
+		t.Errorf("Should only count tweets created inside the time window")
+	}
+}
+
+func TestArchiveTweetersStats(t *testing.T) {
+	ctx := context.Background()
+
+	//
+	result, err := ArchiveTweetersStats(
+		ctx,
+		&tweetsService{
+			tweeters: []*entities.Tweeter{
+				&entities.Tweeter{FullName: "John Smith", Username: "jsmith"},
+				&entities.Tweeter{FullName: "John Smith", Username: "jsmith"},
+			},
+		},
+		TimeWindow{},
+	)
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(result.Stats) != 1 || result.Stats[0].TweetsCount != 2 {
+		t.Errorf("Should return stats without an access token")
+	}
+
+	//
+	result, err = ArchiveTweetersStats(
+		ctx,
+		&tweetsService{err: errors.New("blablabla")},
+		TimeWindow{},
+	)
+
+	if err == nil || result != nil {
+		t.Errorf("Should return an error when TweetService returns an error")
+	}
+}
+
+func TestTimeWindowContains(t *testing.T) {
+	now := time.Now()
+
+	if !(TimeWindow{}).Contains(time.Time{}) {
+		t.Errorf("An empty window should contain everything")
+	}
+
+	window := TimeWindow{Since: now.Add(-time.Hour), Until: now}
+
+	if !window.Contains(now.Add(-time.Minute)) ||
+		window.Contains(now) ||
+		window.Contains(now.Add(-2*time.Hour)) ||
+		window.Contains(time.Time{}) {
+
+		t.Errorf("Whaaat!")
+	}
 }
 
 func TestHandleOauth1Callback(t *testing.T) {
+	client := &oauthClient{
+		parseAuthorizationRequestToken:  "requestToken",
+		verifier:                        "verifier",
+		parseAuthorizationCallbackError: nil,
+
+		accessToken:    "accessToken",
+		accessSecret:   "accessSecret",
+		accessTokenErr: nil,
+	}
+
+	newRequestTokens := func() sessions.RequestTokenStore {
+		requestTokens := sessions.NewRequestTokenStore(time.Minute)
+
+		if err := requestTokens.Save("requestToken", "requestSecret"); err != nil {
+			t.Fatal(err)
+		}
+
+		return requestTokens
+	}
+
 	//
+	requestTokens := newRequestTokens()
 	result, err := Oauth1Callback(
-		&oauthClient{
-			parseAuthorizationRequestToken:  "requestToken",
-			verifier:                        "verifier",
-			parseAuthorizationCallbackError: nil,
-
-			accessToken:    "accessToken",
-			accessSecret:   "accessSecret",
-			accessTokenErr: nil,
-		},
-		"blablabla",
+		context.Background(),
+		client,
+		requestTokens,
+		"requestToken",
 		&http.Request{},
 	)
 
 	if err != nil {
-		t.Errorf(err.Error())
+		t.Error(err)
 	}
 
 	if result.AccessToken != "accessToken" ||
@@ -213,7 +387,26 @@ func TestHandleOauth1Callback(t *testing.T) {
 	}
 
 	//
-	result, err = Oauth1Callback(&oauthClient{}, "blablabla", nil)
+	result, err = Oauth1Callback(
+		context.Background(),
+		client,
+		requestTokens,
+		"requestToken",
+		&http.Request{},
+	)
+
+	if err != ErrLoginReplayed || result != nil {
+		t.Errorf("Should only accept a request token once: %v", err)
+	}
+
+	//
+	result, err = Oauth1Callback(
+		context.Background(),
+		client,
+		newRequestTokens(),
+		"requestToken",
+		nil,
+	)
 
 	if err == nil || result != nil {
 		t.Errorf("Whaaat!")
@@ -221,10 +414,84 @@ func TestHandleOauth1Callback(t *testing.T) {
 
 	//
 	result, err = Oauth1Callback(
+		context.Background(),
+		client,
+		newRequestTokens(),
+		"",
+		&http.Request{},
+	)
+
+	if err != ErrLoginMissing || result != nil {
+		t.Errorf("Should require a login in progress: %v", err)
+	}
+
+	//
+	result, err = Oauth1Callback(
+		context.Background(),
+		client,
+		sessions.NewRequestTokenStore(time.Minute),
+		"requestToken",
+		&http.Request{},
+	)
+
+	if err != ErrLoginMissing || result != nil {
+		t.Errorf("Should require a known request token: %v", err)
+	}
+
+	//
+	result, err = Oauth1Callback(
+		context.Background(),
+		client,
+		newRequestTokens(),
+		"otherRequestToken",
+		&http.Request{},
+	)
+
+	if err != ErrLoginMismatch || result != nil {
+		t.Errorf("Should match the callback to the login: %v", err)
+	}
+
+	//
+	expiredRequestTokens := sessions.NewRequestTokenStore(-time.Minute)
+	expiredRequestTokens.Save("requestToken", "requestSecret")
+	result, err = Oauth1Callback(
+		context.Background(),
+		client,
+		expiredRequestTokens,
+		"requestToken",
+		&http.Request{},
+	)
+
+	if err != ErrLoginExpired || result != nil {
+		t.Errorf("Should reject expired logins: %v", err)
+	}
+
+	//
+	requestTokens = newRequestTokens()
+	result, err = Oauth1Callback(
+		context.Background(),
+		client,
+		requestTokens,
+		"requestToken",
+		&http.Request{URL: &url.URL{RawQuery: "denied=requestToken"}},
+	)
+
+	if err != ErrLoginDenied || result != nil {
+		t.Errorf("Should report denied authorizations: %v", err)
+	}
+
+	if _, err := requestTokens.Take("requestToken"); err != sessions.ErrUsed {
+		t.Errorf("Should forget denied request tokens: %v", err)
+	}
+
+	//
+	result, err = Oauth1Callback(
+		context.Background(),
 		&oauthClient{
 			parseAuthorizationCallbackError: errors.New("blablabla"),
 		},
-		"blablabla",
+		newRequestTokens(),
+		"requestToken",
 		&http.Request{},
 	)
 
@@ -234,28 +501,48 @@ func TestHandleOauth1Callback(t *testing.T) {
 
 	//
 	result, err = Oauth1Callback(
+		context.Background(),
 		&oauthClient{
-			accessTokenErr: errors.New("blablabla"),
+			parseAuthorizationRequestToken: "requestToken",
+			accessTokenErr:                 errors.New("blablabla"),
 		},
-		"blablabla",
+		newRequestTokens(),
+		"requestToken",
 		&http.Request{},
 	)
 
 	if err == nil || result != nil {
 		t.Errorf("Whaaat!")
 	}
+
+	//
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err = Oauth1Callback(
+		canceledCtx,
+		client,
+		newRequestTokens(),
+		"requestToken",
+		&http.Request{},
+	)
+
+	if err == nil || result != nil {
+		t.Errorf("Should pass the context on to client.AccessToken()")
+	}
 }
 
 func TestLogin(t *testing.T) {
 	//
-	result, err := Login(&oauthClient{
+	requestTokens := sessions.NewRequestTokenStore(time.Minute)
+	result, err := Login(context.Background(), &oauthClient{
 		requestToken:  "requestToken",
 		requestSecret: "requestSecret",
 		url:           &url.URL{Path: "blablabla"},
-	})
+	}, requestTokens)
 
 	if err != nil ||
-		result.RequestSecret != "requestSecret" ||
+		result.RequestToken != "requestToken" ||
 		result.AuthorizationURL.Path != "blablabla" {
 
 		t.Errorf(
@@ -263,10 +550,33 @@ func TestLogin(t *testing.T) {
 		)
 	}
 
+	if secret, err := requestTokens.Take("requestToken"); err != nil ||
+		secret != "requestSecret" {
+		t.Errorf("Should keep the request secret server-side: %v", err)
+	}
+
 	//
-	result, err = Login(&oauthClient{requestTokenError: errors.New("blablabla")})
+	result, err = Login(
+		context.Background(),
+		&oauthClient{requestTokenError: errors.New("blablabla")},
+		sessions.NewRequestTokenStore(time.Minute),
+	)
 
 	if err == nil || result != nil {
 		t.Errorf("Whaaat!")
 	}
+
+	//
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err = Login(
+		canceledCtx,
+		&oauthClient{requestToken: "requestToken", requestSecret: "requestSecret"},
+		sessions.NewRequestTokenStore(time.Minute),
+	)
+
+	if err == nil || result != nil {
+		t.Errorf("Should pass the context on to client.RequestToken()")
+	}
 }